@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/config"
+)
+
+// runValidate loads and validates the config, printing every problem, and
+// exits non-zero if there are any, e.g. for CI or a pre-deploy hook.
+func runValidate(args []string) int {
+	fs, configFile := newFlagSet(appName + " validate")
+	offline := fs.Bool("offline", false, "Don't read INFORMER_* and ${VAR} environment variables or _file secrets, warning of the values left unchecked, e.g. for CI")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	load := config.LoadConfig
+	if *offline {
+		load = config.LoadConfigOffline
+	}
+	conf, err := load(*configFile, fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	err = conf.Validate()
+	for _, w := range conf.Warnings() {
+		fmt.Fprintln(os.Stderr, "warning: "+w.String())
+	}
+	if err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			for _, p := range invalid.Problems {
+				fmt.Fprintln(os.Stderr, p.String())
+			}
+			if len(invalid.Problems) == 1 {
+				fmt.Fprintln(os.Stderr, "1 problem found")
+			} else {
+				fmt.Fprintf(os.Stderr, "%d problems found\n", len(invalid.Problems))
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	if len(conf.Warnings()) > 0 {
+		fmt.Println("Config is valid, apart from the values which weren't checked offline.")
+		return 0
+	}
+	fmt.Println("Config is valid.")
+	return 0
+}
+
+func runConfig(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "print":
+			return runConfigPrint(args[1:])
+		case "schema":
+			return runConfigSchema(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s config print|schema [flags]\n", appName)
+	return 2
+}
+
+// runConfigPrint prints the effective config: defaults, overridden by the
+// config file, environment and flags, with secrets redacted.
+func runConfigPrint(args []string) int {
+	fs, configFile := newFlagSet(appName + " config print")
+	format := fs.String("format", "yaml", "Output format, yaml or json")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	conf, err := config.LoadConfig(*configFile, fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	n, err := conf.Redacted()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	switch *format {
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		err = enc.Encode(n)
+	case "json":
+		var v interface{}
+		if err = n.Decode(&v); err == nil {
+			err = writeJSON(v)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q, expected yaml or json", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	stored := filepath.Join(conf.DataDir, config.StoreFile)
+	if _, err := os.Stat(stored); err == nil {
+		fmt.Fprintf(os.Stderr, "Note: the sources and sinks edited at runtime in %s are applied over those above.\n", stored)
+	}
+	return 0
+}
+
+// runConfigSchema prints a JSON Schema of the config file, for editor
+// autocompletion, e.g. with yaml-language-server.
+func runConfigSchema(args []string) int {
+	fs := flag.NewFlagSet(appName+" config schema", flag.ContinueOnError)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := writeJSON(config.Schema()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// fixture holds the API responses recorded while replaying a webhook, keyed
+// by request, e.g. "GET /api/v3/movie/603", so that the webhook can later be
+// replayed offline.
+type fixture map[string]fixtureResponse
+
+type fixtureResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+func fixtureKey(r *http.Request) string {
+	key := r.Method + " " + r.URL.Path
+	if r.URL.RawQuery != "" {
+		key += "?" + r.URL.RawQuery
+	}
+	return key
+}
+
+func loadFixture(path string) (fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := fixture{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f fixture) save(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o600)
+}
+
+// RoundTrip answers requests from the fixture, failing any not recorded.
+func (f fixture) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, ok := f[fixtureKey(r)]
+	if !ok {
+		return nil, fmt.Errorf("fixture: no response recorded for %s", fixtureKey(r))
+	}
+	return &http.Response{
+		Status:     http.StatusText(resp.Status),
+		StatusCode: resp.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(resp.Body)),
+		Request:    r,
+	}, nil
+}
+
+// recorder records the responses to requests made through base.
+type recorder struct {
+	base    http.RoundTripper
+	fixture fixture
+	mut     sync.Mutex // protects fixture
+}
+
+func (rec *recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := rec.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	recorded := fixtureResponse{Status: resp.StatusCode}
+	if json.Valid(b) {
+		recorded.Body = b
+	}
+	rec.mut.Lock()
+	rec.fixture[fixtureKey(r)] = recorded
+	rec.mut.Unlock()
+	return resp, nil
+}
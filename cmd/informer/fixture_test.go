@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFixture(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/movie":
+			w.Write([]byte(`[{"id":603}]`))
+		case "/api/v3/health":
+			w.Write([]byte("OK"))
+		default:
+			http.Error(w, `{"message":"NotFound"}`, http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string // as answered from the fixture
+	}{
+		{"json", "/api/v3/movie?tmdbId=603", http.StatusOK, `[{"id":603}]`},
+		{"error", "/api/v3/movie/1", http.StatusNotFound, `{"message":"NotFound"}`},
+		{"not json", "/api/v3/health", http.StatusOK, ""},
+	}
+
+	rec := &recorder{base: http.DefaultTransport, fixture: fixture{}}
+	client := &http.Client{Transport: rec}
+	for _, tt := range tests {
+		resp, err := client.Get(api.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The live response is passed through whole.
+		if b, _ := io.ReadAll(resp.Body); len(b) == 0 {
+			t.Errorf("%s: live response body was consumed", tt.path)
+		}
+		resp.Body.Close()
+	}
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := rec.fixture.save(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := loadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client = &http.Client{Transport: f}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Answered by key, whatever the host.
+			resp, err := client.Get("http://fixture.invalid" + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			// Saved indented.
+			var compact bytes.Buffer
+			if json.Compact(&compact, b) == nil {
+				b = compact.Bytes()
+			}
+			if resp.StatusCode != tt.wantStatus || string(b) != tt.wantBody {
+				t.Errorf("got %d %s, want %d %s", resp.StatusCode, b, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+	if _, err := client.Get("http://fixture.invalid/api/v3/movie?tmdbId=604"); err == nil {
+		t.Error("unrecorded request succeeded, want an error")
+	}
+}
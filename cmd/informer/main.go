@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the container image has no zoneinfo, and schedules may name a timezone
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	flag "github.com/spf13/pflag"
 
+	"github.com/rtrox/informer/internal/admin"
+	"github.com/rtrox/informer/internal/auth"
 	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/history"
+	"github.com/rtrox/informer/internal/logging"
 	"github.com/rtrox/informer/internal/middleware"
 	"github.com/rtrox/informer/internal/sink"
 	"github.com/rtrox/informer/internal/source"
+	"github.com/rtrox/informer/internal/ui"
 )
 
 var (
@@ -27,10 +35,9 @@ var (
 	revision  = ""
 )
 
-func init() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-}
+// sinkShutdownTimeout bounds how long serve waits for sinks to flush on
+// shutdown.
+const sinkShutdownTimeout = 30 * time.Second
 
 func newHealthCheckHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -38,22 +45,146 @@ func newHealthCheckHandler() http.Handler {
 	})
 }
 
+// handleLogLevelSignals switches to debug logging on SIGUSR1, and back to
+// the configured levels on SIGUSR2.
+func handleLogLevelSignals() {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range sigchan {
+		if sig == syscall.SIGUSR1 {
+			logging.Override(logging.Default, zerolog.DebugLevel)
+			log.Info().Msg("Debug logging enabled, until SIGUSR2.")
+		} else {
+			logging.ResetOverrides()
+			log.Info().Msg("Restored the configured log levels.")
+		}
+	}
+}
+
+// command is a subcommand, e.g. informer validate.
+type command struct {
+	summary string
+	run     func(args []string) int // returns the exit code
+}
+
+var commands map[string]command
+
+func init() {
+	// Assigned here, as serve's usage refers back to commands.
+	commands = map[string]command{
+		"serve":    {"Run Informer (the default)", func(args []string) int { serve(args); return 0 }},
+		"validate": {"Check a config, listing every problem", runValidate},
+		"config":   {"print: show the effective config; schema: emit its JSON Schema", runConfig},
+		"send":     {"Deliver a test event through a sink", runSend},
+		"replay":   {"Run a captured webhook payload through a source", runReplay},
+	}
+}
+
 func main() {
-	configFile := flag.String("config", "config.yaml", "Path to config file")
-	debug := flag.Bool("debug", false, "Enable debug logging")
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			os.Exit(cmd.run(args[1:]))
+		}
+		if args[0] == "help" {
+			usage()
+			return
+		}
+	}
+	serve(args)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", appName)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> --help for its flags.\n", appName)
+}
+
+// newFlagSet returns a flag set with --config, and a flag for each config
+// key, as shared by the commands which load the config.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "Path to config file, optional (env "+config.EnvConfigFile+"). Defaults to "+config.DefaultConfigFile+", if it exists.")
+	config.RegisterFlags(fs)
+	return fs, configFile
+}
+
+// parseFlags parses args into fs. If the command shouldn't go on to run,
+// after --help or a bad flag, it returns false, and the exit code.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	switch {
+	case err == nil:
+		return 0, true
+	case errors.Is(err, flag.ErrHelp):
+		return 0, false
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		if fs.Usage != nil {
+			fs.Usage()
+		} else {
+			fmt.Fprintf(os.Stderr, "Flags:\n%s", fs.FlagUsages())
+		}
+		return 2, false
+	}
+}
+
+// setupLogging applies the configured log format and levels.
+func setupLogging(conf *config.Config) error {
+	if err := logging.Setup(conf.LogFormat); err != nil {
+		return err
+	}
+	levels, err := conf.BuildLogLevels()
+	if err != nil {
+		return err
+	}
+	return logging.SetLevels(levels)
+}
+
+func serve(args []string) {
+	fs, configFile := newFlagSet(appName)
+	debug := fs.Bool("debug", false, "Enable debug logging")
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintf(os.Stderr, "\nFlags:\n%s", fs.FlagUsages())
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		os.Exit(code)
+	}
 
 	if *debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		logging.Override(logging.Default, zerolog.DebugLevel)
 	}
-	conf, err := config.LoadConfig(*configFile)
+	conf, err := config.LoadConfig(*configFile, fs)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load config")
 	}
+	if *debug {
+		conf.LogLevel = "debug"
+		logging.ResetOverrides()
+	}
 
 	if err := conf.Validate(); err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			for _, p := range invalid.Problems {
+				log.Error().Msg(p.String())
+			}
+			log.Fatal().Int("problems", len(invalid.Problems)).Msg("Invalid config")
+		}
+		log.Fatal().Err(err).Msg("Invalid config")
+	}
+	if err := setupLogging(conf); err != nil {
 		log.Fatal().Err(err).Msg("Invalid config")
 	}
+	go handleLogLevelSignals()
 
 	var srv http.Server
 
@@ -85,18 +216,40 @@ func main() {
 		Str("revision", revision).
 		Msg("Informer Started.")
 
-	sinkManager := sink.NewSinkManager(sink.SinkManagerOpts{
+	keyring, err := conf.Encryption.Build()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load encryption keys")
+	}
+
+	var store *history.Store
+	if conf.History.Enabled {
+		store, err = history.Open(conf.History.Build(conf.DataDir, keyring))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open event history")
+		}
+	}
+
+	sinkOpts := sink.SinkManagerOpts{
 		QueueLength:     conf.QueueSize,
 		SinkQueueLength: conf.SinkQueueSize,
-	})
+		Dedup: sink.DedupOpts{
+			Window: conf.Dedup.Window,
+			Fields: conf.Dedup.Fingerprint,
+		},
+	}
+	if store != nil {
+		sinkOpts.Recorder = store
+	}
+	sinkManager := sink.NewSinkManager(sinkOpts)
 
 	done := make(chan struct{})
-	sinkManager.Start(done)
-
-	config.UpdateSinkManagerConfig(sinkManager, conf.Sinks)
+	sinksStopped := sinkManager.Start(done)
 
 	sourceManager := source.NewSourceManager()
-	config.UpdateSourceManagerConfig(sourceManager, conf.Sources)
+	configStore, err := config.NewStore(conf, sinkManager, sourceManager, keyring)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config store")
+	}
 
 	router := chi.NewRouter()
 	router.Handle("/healthz", newHealthCheckHandler())
@@ -105,10 +258,36 @@ func main() {
 		r.Use(
 			// TODO: move event middleware into SourceManager's Routes() func
 			middleware.PublishEventMiddleware(sinkManager),
-			middleware.LogRequestBodyMiddleware,
+			middleware.LogRequestBodyMiddleware(logging.For(logging.Sources)),
 		)
 		r.Mount("/", sourceManager.Routes())
 	})
+	authOpts, err := conf.Auth.Build(conf.Admin, conf.DataDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid auth config")
+	}
+	authenticator, err := auth.New(authOpts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load users")
+	}
+
+	// Everything but /healthz and /webhook, which have their own auth,
+	// requires a signed in user or API token.
+	router.Post("/auth/login", authenticator.HandleLogin)
+	for _, public := range ui.Public {
+		router.Handle("/ui/"+public, http.StripPrefix("/ui/", ui.Handler()))
+	}
+	router.Group(func(r chi.Router) {
+		r.Use(authenticator.Middleware)
+		r.Route("/api/v1", func(r chi.Router) {
+			r.Mount("/auth", authenticator.Routes())
+			r.Mount("/", admin.New(sinkManager, sourceManager, store, configStore).Routes())
+		})
+		r.Handle("/ui/*", http.StripPrefix("/ui/", ui.Handler()))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/ui/", http.StatusFound)
+		})
+	})
 
 	srv.Addr = fmt.Sprintf("%s:%d", conf.Interface, conf.Port)
 	srv.Handler = router
@@ -119,5 +298,18 @@ func main() {
 	<-idleConnsClosed
 
 	close(done)
+	// Let the sinks flush before closing the history they record into.
+	select {
+	case <-sinksStopped:
+	case <-time.After(sinkShutdownTimeout):
+		log.Warn().
+			Dur("timeout", sinkShutdownTimeout).
+			Msg("Timed out waiting for sinks to stop.")
+	}
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close event history")
+		}
+	}
 	log.Info().Msg("Informer Stopped.")
 }
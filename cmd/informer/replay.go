@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"golift.io/starr"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/source"
+)
+
+// How a replayed source's API calls, which enrich its events, are answered.
+const (
+	enrichLive    = "live"    // by the source's API, as configured
+	enrichOff     = "off"     // not made at all
+	enrichFixture = "fixture" // from responses recorded with --record
+)
+
+// runReplay runs a captured webhook payload through a source, offline,
+// printing the resulting event, and optionally delivering it to a sink.
+func runReplay(args []string) int {
+	fs, configFile := newFlagSet(appName + " replay")
+	sourceName := fs.String("source", "", "Name of the source to replay through, or a source type (required)")
+	sinkName := fs.String("sink", "", "Also deliver the event through this sink")
+	enrich := fs.String("enrich", enrichLive, "Answer the source's API calls live, off (skip them), or from a fixture")
+	fixturePath := fs.String("fixture", "", "API responses recorded with --record, for --enrich fixture")
+	record := fs.String("record", "", "Record the live API responses to this file, for later use with --enrich fixture")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s replay --source NAME [flags] PAYLOAD\n\nPAYLOAD is a webhook body, as JSON, or a file written by a source's\ncapture mode, or - for stdin.\n\nFlags:\n%s", appName, fs.FlagUsages())
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *sourceName == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *record != "" && *enrich != enrichLive {
+		fmt.Fprintln(os.Stderr, "--record requires --enrich live")
+		return 2
+	}
+	var rec *recorder
+	opts := source.Opts{Log: logging.For(logging.Sources)}
+	switch *enrich {
+	case enrichLive:
+		if *record != "" {
+			rec = &recorder{base: starr.Client(0, false).Transport, fixture: fixture{}}
+			opts.Transport = rec
+		}
+	case enrichOff:
+	case enrichFixture:
+		if *fixturePath == "" {
+			fmt.Fprintln(os.Stderr, "--fixture is required with --enrich fixture")
+			return 2
+		}
+		f, err := loadFixture(*fixturePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		opts.Transport = f
+	default:
+		fmt.Fprintf(os.Stderr, "unknown --enrich %q, expected %s, %s or %s\n", *enrich, enrichLive, enrichOff, enrichFixture)
+		return 2
+	}
+
+	payload, err := readPayload(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	conf, err := loadRuntimeConfig(*configFile, fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	src, err := replaySource(conf, *sourceName, *enrich, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook/"+*sourceName, bytes.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	e, err := src.HandleHTTP(httptest.NewRecorder(), r)
+	if rec != nil {
+		if err := rec.fixture.save(*record); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %d API responses to %s.\n", len(rec.fixture), *record)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "source %s: %s\n", *sourceName, err)
+		return 1
+	}
+	e.Stamp()
+	if err := printEvent(e); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *sinkName != "" {
+		if err := deliver(conf, *sinkName, e); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// readPayload reads a webhook body, or the body of a request captured by a
+// source's capture mode.
+func readPayload(path string) ([]byte, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var captured source.Captured
+	if json.Unmarshal(b, &captured) == nil && !captured.CapturedAt.IsZero() {
+		if captured.Body != nil {
+			return captured.Body, nil
+		}
+		return []byte(captured.BodyText), nil
+	}
+	return b, nil
+}
+
+// replaySource builds the named source, with opts. A source type may be
+// named in place of a configured source, in which case it has no API
+// connection, unless enrich is fixture, for which a placeholder is used.
+func replaySource(conf *config.Config, name string, enrich string, opts source.Opts) (source.Source, error) {
+	typ, configured := name, false
+	for _, c := range conf.Sources {
+		if c.Name == name {
+			typ, configured = c.Type, true
+		}
+	}
+	if configured && enrich != enrichOff {
+		entry, err := config.BuildSource(conf, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		return entry.Source, nil
+	}
+
+	var node yaml.Node
+	if enrich == enrichFixture {
+		if err := node.Encode(map[string]string{"url": "http://fixture.invalid", "api-key": "fixture"}); err != nil {
+			return nil, err
+		}
+	}
+	s, err := source.MakeSource(typ, node, opts)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// printEvent prints e as JSON, naming its type, as accepted by send --file.
+func printEvent(e event.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	v["type"] = e.EventType.String()
+	return writeJSON(v)
+}
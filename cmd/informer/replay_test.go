@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/source"
+)
+
+const radarrGrab = `{"eventType":"Grab","movie":{"id":603,"title":"The Matrix","year":1999},"release":{"quality":"Bluray-1080p"}}`
+
+// replayConfig returns a config with a radarr source named movies, whose
+// API is at url.
+func replayConfig(t *testing.T, url string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	conf := fmt.Sprintf("data-dir: %s\nsources:\n  - name: movies\n    type: radarr\n    config:\n      url: %s\n      api-key: abcdef123456\n", dir, url)
+	if err := os.WriteFile(path, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.LoadConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// replay runs payload through the source built by replaySource.
+func replay(t *testing.T, conf *config.Config, name string, enrich string, opts source.Opts, payload string) (event.Event, error) {
+	t.Helper()
+	src, err := replaySource(conf, name, enrich, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/webhook/"+name, strings.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	return src.HandleHTTP(httptest.NewRecorder(), r)
+}
+
+func TestReplayEnrichment(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !strings.HasSuffix(r.URL.Path, "/movie/603") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":603,"overview":"A hacker learns the truth.","runtime":136}`)
+	}))
+	defer api.Close()
+	conf := replayConfig(t, api.URL)
+	fixturePath := filepath.Join(t.TempDir(), "fixture.json")
+
+	// Live, recording the API's responses.
+	rec := &recorder{base: http.DefaultTransport, fixture: fixture{}}
+	e, err := replay(t, conf, "movies", enrichLive, source.Opts{Transport: rec}, radarrGrab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Metadata.Get("overview"); got == nil || got.Value != "A hacker learns the truth." {
+		t.Fatalf("overview = %v, want it from the API", got)
+	}
+	if err := rec.fixture.save(fixturePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Off, for a configured source, makes no API calls.
+	before := calls.Load()
+	if e, err = replay(t, conf, "movies", enrichOff, source.Opts{}, radarrGrab); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != before || e.Metadata.Get("overview") != nil {
+		t.Errorf("enrich off called the API")
+	}
+
+	// From the fixture, by type, with the API gone.
+	api.Close()
+	f, err := loadFixture(fixturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err = replay(t, conf, "radarr", enrichFixture, source.Opts{Transport: f}, radarrGrab); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Metadata.Get("overview"); got == nil || got.Value != "A hacker learns the truth." {
+		t.Errorf("overview = %v, want it from the fixture", got)
+	}
+	if e.Title != "[Grabbed] The Matrix" {
+		t.Errorf("Title = %q", e.Title)
+	}
+
+	// Requests the fixture didn't record fail.
+	other := strings.Replace(radarrGrab, "603", "604", 1)
+	if _, err := replay(t, conf, "radarr", enrichFixture, source.Opts{Transport: f}, other); err == nil || !strings.Contains(err.Error(), "no response recorded for GET") {
+		t.Errorf("replaying an unrecorded movie = %v, want a missing fixture error", err)
+	}
+}
+
+func TestReadPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"webhook body", radarrGrab, radarrGrab},
+		{"captured JSON", `{"captured_at":"2024-05-01T12:00:00Z","source":"movies","body":` + radarrGrab + `}`, radarrGrab},
+		{"captured text", `{"captured_at":"2024-05-01T12:00:00Z","source":"movies","body_text":"eventType=Test"}`, "eventType=Test"},
+		{"JSON without capture fields", `{"body":"not a capture"}`, `{"body":"not a capture"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "payload.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPayload(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, []byte(tt.want)) {
+				t.Errorf("readPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayWithoutAPI(t *testing.T) {
+	const sonarrDownload = `{"eventType":"Download","series":{"id":1,"title":"Severance"},"episodes":[{"id":11,"seasonNumber":1,"episodeNumber":1,"title":"Good News About Hell"}],"episodeFile":{"quality":"WEBDL-1080p","size":1073741824,"releaseGroup":"NTb","mediaInfo":{"videoCodec":"h264","audioCodec":"EAC3"}}}`
+
+	// Without a url, the source has no API client, so the event is built
+	// from the payload alone.
+	e, err := replay(t, replayConfig(t, "http://radarr:7878"), "sonarr", enrichOff, source.Opts{}, sonarrDownload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"quality":       "WEBDL-1080p",
+		"codecs":        "h264 / EAC3",
+		"release_group": "NTb",
+	} {
+		if got := e.Metadata.Get(key); got == nil || got.Value != want {
+			t.Errorf("%s = %v, want %q", key, got, want)
+		}
+	}
+	if e.Metadata.Get("file_size") == nil {
+		t.Error("file_size is missing")
+	}
+	if e.Metadata.Get("overview") != nil {
+		t.Error("overview is set, without an API to fetch it from")
+	}
+}
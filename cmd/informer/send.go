@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/event"
+)
+
+// runSend delivers one event through a configured sink, and waits for the
+// result, e.g. to check a new Discord webhook without waiting on a source.
+func runSend(args []string) int {
+	fs, configFile := newFlagSet(appName + " send")
+	sinkName := fs.String("sink", "", "Name of the sink to send through (required)")
+	typeName := fs.String("type", event.TestEvent.String(), "Send a sample event of this type")
+	file := fs.String("file", "", "Send the event in this JSON file, or - for stdin, in place of a sample")
+	title := fs.String("title", "", "Override the event's title")
+	description := fs.String("description", "", "Override the event's description")
+	source := fs.String("source", "", "Override the event's source")
+	metadata := fs.StringArray("metadata", nil, "Add a metadata field, as Name=Value. Repeatable")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *sinkName == "" {
+		fmt.Fprintln(os.Stderr, "--sink is required")
+		return 2
+	}
+	e, err := sendEvent(*typeName, *file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *title != "" {
+		e.Title = *title
+	}
+	if *description != "" {
+		e.Description = *description
+	}
+	if *source != "" {
+		e.Source = *source
+	}
+	for _, m := range *metadata {
+		name, value, ok := strings.Cut(m, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "invalid --metadata %q, expected Name=Value\n", m)
+			return 2
+		}
+		e.Metadata.Add(name, value)
+	}
+
+	conf, err := loadRuntimeConfig(*configFile, fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := deliver(conf, *sinkName, e); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// loadRuntimeConfig loads the config, and sets up logging, as serve would,
+// including any sources and sinks saved at runtime.
+func loadRuntimeConfig(configFile string, fs *flag.FlagSet) (*config.Config, error) {
+	conf, err := config.LoadConfig(configFile, fs)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupLogging(conf); err != nil {
+		return nil, err
+	}
+	if _, err := config.LoadStored(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// deliver sends e through the named sink, and waits for the result.
+func deliver(conf *config.Config, sinkName string, e event.Event) error {
+	s, filter, err := config.BuildSink(conf, sinkName)
+	if err != nil {
+		return err
+	}
+	defer s.Done()
+
+	if !filter.Match(e) {
+		fmt.Fprintf(os.Stderr, "Note: sink %s's filter would drop this event, sending it anyway.\n", sinkName)
+	}
+	if err := s.ProcessEvent(e); err != nil {
+		return fmt.Errorf("sink %s: %w", sinkName, err)
+	}
+	fmt.Fprintf(os.Stderr, "Sent %s %q to sink %s.\n", e.EventType, e.Title, sinkName)
+	return nil
+}
+
+// sendEvent returns the event in file, or a sample of the named type.
+func sendEvent(typeName string, file string) (event.Event, error) {
+	if file == "" {
+		t, err := event.ParseEventType(typeName)
+		if err != nil {
+			return event.Event{}, err
+		}
+		return event.Sample(t), nil
+	}
+	var b []byte
+	var err error
+	if file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return event.Event{}, err
+	}
+	e, err := decodeEvent(b)
+	if err != nil {
+		return event.Event{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return e, nil
+}
+
+// decodeEvent decodes an event.Event from JSON, as stored in the history,
+// also accepting its type by name, e.g. "type": "ObjectGrabbed".
+func decodeEvent(b []byte) (event.Event, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return event.Event{}, err
+	}
+	if name, ok := raw["type"].(string); ok {
+		t, err := event.ParseEventType(name)
+		if err != nil {
+			return event.Event{}, err
+		}
+		raw["type"] = int(t)
+		if b, err = json.Marshal(raw); err != nil {
+			return event.Event{}, err
+		}
+	}
+	var e event.Event
+	if err := json.Unmarshal(b, &e); err != nil {
+		return event.Event{}, err
+	}
+	if e.Title == "" {
+		return event.Event{}, errors.New("the event has no title")
+	}
+	e.Stamp()
+	return e, nil
+}
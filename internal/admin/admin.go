@@ -0,0 +1,167 @@
+// Package admin serves the /api/v1 admin API, for inspecting and
+// controlling a running Informer.
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/history"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+type API struct {
+	sinks   *sink.SinkManager
+	sources *source.SourceManager
+	history *history.Store // nil unless history is enabled
+	config  *config.Store
+}
+
+func New(sinks *sink.SinkManager, sources *source.SourceManager, store *history.Store, conf *config.Store) *API {
+	return &API{
+		sinks:   sinks,
+		sources: sources,
+		history: store,
+		config:  conf,
+	}
+}
+
+// Routes serves:
+//
+//	GET    /health               overall health, and that of each source and sink
+//	GET    /stats                queue depths and counters
+//	GET    /sources              configured sources
+//	GET    /sinks                configured sinks
+//	GET    /sinks/{name}         a single sink
+//	POST   /sinks/{name}/pause   stop delivering to the sink, queueing events
+//	POST   /sinks/{name}/resume  resume delivering to the sink
+//	POST   /sinks/{name}/drain   discard the sink's queued events
+//	POST   /sinks/{name}/test    queue a test event for the sink
+//	GET    /log-levels           log level of each component
+//	PUT    /log-levels           override log levels until restart, e.g. {"sinks/discord": "debug"}
+//	DELETE /log-levels           remove the overrides, restoring the configured levels
+//	GET    /events               event history, if enabled. See history.Store.Routes.
+//	       /config               the sources and sinks config. See configRoutes.
+func (a *API) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/health", a.handleHealth)
+	router.Get("/stats", a.handleStats)
+	router.Get("/sources", a.handleSources)
+	router.Route("/sinks", func(r chi.Router) {
+		r.Get("/", a.handleSinks)
+		r.Get("/{name}", a.handleSink)
+		r.Post("/{name}/pause", a.handlePause)
+		r.Post("/{name}/resume", a.handleResume)
+		r.Post("/{name}/drain", a.handleDrain)
+		r.Post("/{name}/test", a.handleTest)
+	})
+	router.Get("/log-levels", a.handleLogLevels)
+	router.Put("/log-levels", a.handleSetLogLevels)
+	router.Delete("/log-levels", a.handleResetLogLevels)
+	if a.history != nil {
+		router.Mount("/events", a.history.Routes())
+	}
+	router.Mount("/config", a.configRoutes())
+	return router
+}
+
+func (a *API) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := sink.HealthOK
+	sinks := make(map[string]string)
+	for _, s := range a.sinks.Sinks() {
+		sinks[s.Name] = s.Health
+		if s.Health != sink.HealthOK {
+			status = "degraded"
+		}
+	}
+	sources := make(map[string]string)
+	for _, s := range a.sources.Sources() {
+		sources[s.Name] = s.Health
+		if s.Health != sink.HealthOK {
+			status = "degraded"
+		}
+	}
+	render.JSON(w, r, map[string]interface{}{
+		"status":  status,
+		"sinks":   sinks,
+		"sources": sources,
+	})
+}
+
+func (a *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, map[string]interface{}{
+		"manager": a.sinks.Stats(),
+		"sinks":   a.sinks.SinkStats(),
+	})
+}
+
+func (a *API) handleSources(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, map[string]interface{}{"sources": a.sources.Sources()})
+}
+
+func (a *API) handleSinks(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, map[string]interface{}{"sinks": a.sinks.Sinks()})
+}
+
+func (a *API) handleSink(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	for _, s := range a.sinks.Sinks() {
+		if s.Name == name {
+			render.JSON(w, r, s)
+			return
+		}
+	}
+	renderError(w, r, sink.ErrSinkNotFound)
+}
+
+func (a *API) handlePause(w http.ResponseWriter, r *http.Request) {
+	if err := a.sinks.PauseSink(chi.URLParam(r, "name")); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "sink paused"})
+}
+
+func (a *API) handleResume(w http.ResponseWriter, r *http.Request) {
+	if err := a.sinks.ResumeSink(chi.URLParam(r, "name")); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "sink resumed"})
+}
+
+func (a *API) handleDrain(w http.ResponseWriter, r *http.Request) {
+	n, err := a.sinks.DrainSink(chi.URLParam(r, "name"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "sink drained", "drained": n})
+}
+
+func (a *API) handleTest(w http.ResponseWriter, r *http.Request) {
+	e, err := a.sinks.SendTestEvent(chi.URLParam(r, "name"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusAccepted, "message": "test event queued", "id": e.ID})
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, sink.ErrSinkNotFound) || errors.Is(err, config.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, config.ErrReadOnly):
+		code = http.StatusConflict
+	}
+	render.Status(r, code)
+	render.JSON(w, r, map[string]interface{}{"code": code, "message": err.Error()})
+}
@@ -0,0 +1,196 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/rtrox/informer/internal/auth"
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/redact"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+const (
+	testToken   = "admin-test-token"
+	testWebhook = "https://discord.com/api/webhooks/123/admin-test-secret"
+)
+
+// newTestAPI serves the admin API as the server does, behind auth, with a
+// log sink, and a discord sink which is never sent anything.
+func newTestAPI(t *testing.T) (*config.Store, http.Handler) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	conf := "data-dir: " + dir + "\n" +
+		"sinks:\n" +
+		"  - {name: log, type: log}\n" +
+		"  - {name: discord, type: discord-webhook, config: {webhook_url: \"" + testWebhook + "\"}}\n"
+	if err := os.WriteFile(path, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.LoadConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks := sink.NewSinkManager(sink.SinkManagerOpts{QueueLength: 10, SinkQueueLength: 10})
+	store, err := config.NewStore(c, sinks, source.NewSourceManager(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Opts{Path: filepath.Join(dir, "auth.json"), StaticTokens: []string{testToken}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := chi.NewRouter()
+	router.Use(authenticator.Middleware)
+	router.Mount("/", New(sinks, source.NewSourceManager(), nil, store).Routes())
+	return store, router
+}
+
+// call makes an authenticated request, checks its status, and decodes the
+// JSON response into out, if not nil.
+func call(t *testing.T, h http.Handler, method, path, body string, want int, out interface{}) {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != want {
+		t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, strings.TrimSpace(w.Body.String()), want)
+	}
+	if out != nil {
+		if err := json.NewDecoder(w.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+func TestSinkControls(t *testing.T) {
+	_, h := newTestAPI(t)
+	status := func() sink.SinkStatus {
+		t.Helper()
+		s := sink.SinkStatus{}
+		call(t, h, http.MethodGet, "/sinks/log", "", http.StatusOK, &s)
+		return s
+	}
+
+	call(t, h, http.MethodPost, "/sinks/log/pause", "", http.StatusOK, nil)
+	if !status().Stats.Paused {
+		t.Fatal("sink not paused")
+	}
+	// Queued while paused.
+	test := map[string]interface{}{}
+	call(t, h, http.MethodPost, "/sinks/log/test", "", http.StatusAccepted, &test)
+	if test["id"] == "" {
+		t.Errorf("test response = %v, want the event's id", test)
+	}
+	if queued := status().Stats.Queued; queued != 1 {
+		t.Errorf("queued = %d, want the test event", queued)
+	}
+	drained := struct {
+		Drained int `json:"drained"`
+	}{}
+	call(t, h, http.MethodPost, "/sinks/log/drain", "", http.StatusOK, &drained)
+	if drained.Drained != 1 {
+		t.Errorf("drained = %d, want 1", drained.Drained)
+	}
+	if s := status(); s.Stats.Queued != 0 || s.Stats.Dropped != 1 {
+		t.Errorf("after draining, queued = %d and dropped = %d, want 0 and 1", s.Stats.Queued, s.Stats.Dropped)
+	}
+	call(t, h, http.MethodPost, "/sinks/log/resume", "", http.StatusOK, nil)
+	if status().Stats.Paused {
+		t.Fatal("sink still paused")
+	}
+
+	for _, action := range []string{"pause", "resume", "drain", "test"} {
+		call(t, h, http.MethodPost, "/sinks/missing/"+action, "", http.StatusNotFound, nil)
+	}
+	call(t, h, http.MethodGet, "/sinks/missing", "", http.StatusNotFound, nil)
+}
+
+func TestLogLevels(t *testing.T) {
+	_, h := newTestAPI(t)
+	t.Cleanup(logging.ResetOverrides)
+	levels := func(method, body string) map[string]logLevel {
+		t.Helper()
+		resp := struct {
+			Levels []logLevel `json:"levels"`
+		}{}
+		call(t, h, method, "/log-levels", body, http.StatusOK, &resp)
+		byComponent := make(map[string]logLevel)
+		for _, l := range resp.Levels {
+			byComponent[l.Component] = l
+		}
+		return byComponent
+	}
+
+	if _, ok := levels(http.MethodGet, "")[logging.Default]; !ok {
+		t.Errorf("no %s level", logging.Default)
+	}
+	got := levels(http.MethodPut, `{"sinks/log": "trace"}`)[logging.Sink("log")]
+	if got.Level != "trace" || !got.Overridden {
+		t.Errorf("sinks/log = %+v, want trace, overridden", got)
+	}
+	for _, body := range []string{`{"sinks/": "debug"}`, `{"webhooks": "debug"}`, `{"default": "loud"}`, `not json`} {
+		call(t, h, http.MethodPut, "/log-levels", body, http.StatusBadRequest, nil)
+	}
+	if got := levels(http.MethodDelete, "")[logging.Sink("log")]; got.Overridden {
+		t.Errorf("sinks/log = %+v after reset, want it no longer overridden", got)
+	}
+}
+
+func TestConfigRedaction(t *testing.T) {
+	store, h := newTestAPI(t)
+
+	served := map[string]interface{}{}
+	call(t, h, http.MethodGet, "/config/sinks/discord", "", http.StatusOK, &served)
+	conf, _ := served["config"].(map[string]interface{})
+	if conf["webhook_url"] != redact.Placeholder {
+		t.Fatalf("served webhook_url = %v, want it redacted", conf["webhook_url"])
+	}
+	for _, path := range []string{"/config/sinks", "/config/sinks/discord?format=yaml"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+testToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if strings.Contains(w.Body.String(), "admin-test-secret") {
+			t.Errorf("GET %s served the webhook's secret:\n%s", path, w.Body.String())
+		}
+	}
+
+	// Written back with the secret left redacted, as the UI does.
+	served["log-level"] = "debug"
+	body, err := json.Marshal(served)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, h, http.MethodPut, "/config/sinks/discord", string(body), http.StatusOK, nil)
+	for _, c := range store.Sinks() {
+		if c.Name != "discord" {
+			continue
+		}
+		if c.LogLevel != "debug" {
+			t.Errorf("log-level = %q, want the edit saved", c.LogLevel)
+		}
+		if got := c.Config.Content[1].Value; got != testWebhook {
+			t.Errorf("webhook_url = %q, want it restored", got)
+		}
+	}
+
+	call(t, h, http.MethodPut, "/config/sinks/discord", `{"type": "discord-webhook", "config": {"webhook-url": "x"}}`, http.StatusBadRequest, nil)
+	call(t, h, http.MethodGet, "/config/sinks/missing", "", http.StatusNotFound, nil)
+	call(t, h, http.MethodDelete, "/config/sinks/missing", "", http.StatusNotFound, nil)
+	call(t, h, http.MethodDelete, "/config/sinks/discord", "", http.StatusOK, nil)
+	if len(store.Sinks()) != 1 {
+		t.Errorf("sinks = %d after deleting discord, want 1", len(store.Sinks()))
+	}
+}
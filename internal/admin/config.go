@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/config"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/redact"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+const maxConfigBodySize = 1 << 20
+
+// configRoutes serves the running sources and sinks config. Configs are
+// read as JSON, or as YAML with ?format=yaml, and written as either.
+//
+//	GET    /types            registered source and sink types, and event types
+//	GET    /sources          every source
+//	GET    /sources/{name}   a single source
+//	PUT    /sources/{name}   add or replace a source
+//	DELETE /sources/{name}   remove a source
+//	...and the same for /sinks.
+func (a *API) configRoutes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/types", a.handleTypes)
+	router.Route("/sources", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			renderConfig(w, r, map[string]interface{}{"sources": a.config.Sources()})
+		})
+		r.Get("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			for _, c := range a.config.Sources() {
+				if c.Name == chi.URLParam(r, "name") {
+					renderConfig(w, r, c)
+					return
+				}
+			}
+			renderError(w, r, config.ErrNotFound)
+		})
+		r.Put("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			c := config.SourceConfig{}
+			if err := decodeConfig(r, &c); err != nil {
+				renderBadRequest(w, r, err)
+				return
+			}
+			c.Name = chi.URLParam(r, "name")
+			if err := a.config.PutSource(c); err != nil {
+				renderBadRequest(w, r, err)
+				return
+			}
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "source saved"})
+		})
+		r.Delete("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			if err := a.config.DeleteSource(chi.URLParam(r, "name")); err != nil {
+				renderError(w, r, err)
+				return
+			}
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "source deleted"})
+		})
+	})
+	router.Route("/sinks", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			renderConfig(w, r, map[string]interface{}{"sinks": a.config.Sinks()})
+		})
+		r.Get("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			for _, c := range a.config.Sinks() {
+				if c.Name == chi.URLParam(r, "name") {
+					renderConfig(w, r, c)
+					return
+				}
+			}
+			renderError(w, r, config.ErrNotFound)
+		})
+		r.Put("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			c := config.SinkConfig{}
+			if err := decodeConfig(r, &c); err != nil {
+				renderBadRequest(w, r, err)
+				return
+			}
+			c.Name = chi.URLParam(r, "name")
+			if err := a.config.PutSink(c); err != nil {
+				renderBadRequest(w, r, err)
+				return
+			}
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "sink saved"})
+		})
+		r.Delete("/{name}", func(w http.ResponseWriter, r *http.Request) {
+			if err := a.config.DeleteSink(chi.URLParam(r, "name")); err != nil {
+				renderError(w, r, err)
+				return
+			}
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "sink deleted"})
+		})
+	})
+	return router
+}
+
+func (a *API) handleTypes(w http.ResponseWriter, r *http.Request) {
+	var eventTypes []string
+	for t := event.ObjectAdded; t <= event.TestEvent; t++ {
+		eventTypes = append(eventTypes, t.String())
+	}
+	render.JSON(w, r, map[string]interface{}{
+		"sources":     source.Types(),
+		"sinks":       sink.Types(),
+		"event_types": eventTypes,
+	})
+}
+
+// decodeConfig reads a YAML, or JSON, request body into v.
+func decodeConfig(r *http.Request, v interface{}) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxConfigBodySize))
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// renderConfig renders v by way of its YAML form, so that field names match
+// the config file, with secrets redacted. Redacted values written back
+// unchanged are restored by the config store.
+func renderConfig(w http.ResponseWriter, r *http.Request, v interface{}) {
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	redact.Node(n)
+	if r.URL.Query().Get("format") == "yaml" {
+		b, err := yaml.Marshal(n)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(b)
+		return
+	}
+	var out interface{}
+	if err := n.Decode(&out); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.JSON(w, r, out)
+}
+
+// renderBadRequest renders err as a 400, unless it's an error renderError
+// has a status for, e.g. editing a read only source or sink.
+func renderBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, config.ErrReadOnly) {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusBadRequest, "message": err.Error()})
+}
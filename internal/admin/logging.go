@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rtrox/informer/internal/auth"
+	"github.com/rtrox/informer/internal/logging"
+)
+
+type logLevel struct {
+	Component  string `json:"component"`
+	Level      string `json:"level"`
+	Overridden bool   `json:"overridden"` // Set at runtime, in place of the configured level.
+}
+
+func (a *API) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	levels, overridden := logging.Levels()
+	out := make([]logLevel, 0, len(levels))
+	for component, level := range levels {
+		out = append(out, logLevel{Component: component, Level: level.String(), Overridden: overridden[component]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Component < out[j].Component })
+	render.JSON(w, r, map[string]interface{}{"levels": out})
+}
+
+// handleSetLogLevels overrides the levels of the components in a JSON
+// object, e.g. {"default": "debug", "sinks/discord": "trace"}, until
+// restart. An empty level removes a component's override.
+func (a *API) handleSetLogLevels(w http.ResponseWriter, r *http.Request) {
+	body := map[string]string{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigBodySize)).Decode(&body); err != nil {
+		renderBadRequest(w, r, err)
+		return
+	}
+	levels := make(map[string]zerolog.Level, len(body))
+	for component, level := range body {
+		if err := validComponent(component); err != nil {
+			renderBadRequest(w, r, err)
+			return
+		}
+		if level == "" {
+			levels[component] = zerolog.NoLevel
+			continue
+		}
+		l, err := logging.ParseLevel(level)
+		if err != nil {
+			renderBadRequest(w, r, fmt.Errorf("%s: %w", component, err))
+			return
+		}
+		levels[component] = l
+	}
+	for component, level := range levels {
+		logging.Override(component, level)
+	}
+	log.Info().
+		Interface("levels", body).
+		Str("by", auth.GetIdentity(r.Context()).Username).
+		Msg("Log levels overridden.")
+	a.handleLogLevels(w, r)
+}
+
+func (a *API) handleResetLogLevels(w http.ResponseWriter, r *http.Request) {
+	logging.ResetOverrides()
+	a.handleLogLevels(w, r)
+}
+
+func validComponent(component string) error {
+	switch component {
+	case logging.Default, logging.Broker, logging.Sources, logging.Sinks:
+		return nil
+	}
+	if name, ok := strings.CutPrefix(component, logging.Sinks+"/"); ok && name != "" {
+		return nil
+	}
+	return fmt.Errorf("unknown log component %q, expected %s, %s, %s, %s or %s", component,
+		logging.Default, logging.Broker, logging.Sources, logging.Sinks, logging.Sink("<name>"))
+}
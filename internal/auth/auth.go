@@ -0,0 +1,315 @@
+// Package auth authenticates requests to the web UI and admin API, by
+// session cookie, API token or a trusted reverse proxy's user header.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SessionCookie     = "informer_session"
+	CSRFHeader        = "X-CSRF-Token"
+	DefaultSessionTTL = 24 * time.Hour
+)
+
+type Method string
+
+const (
+	MethodSession Method = "session"
+	MethodToken   Method = "token"
+	MethodProxy   Method = "proxy"
+)
+
+// ProxyOpts trusts a reverse proxy, e.g. Authelia, to authenticate users,
+// and name them in a request header.
+type ProxyOpts struct {
+	Header         string         // e.g. Remote-User. Proxy auth is disabled if empty.
+	TrustedProxies []netip.Prefix // Only requests from these addresses may set Header.
+}
+
+type Opts struct {
+	Path         string        // File in which users and tokens created at runtime are kept.
+	Users        []User        // Users from the config file, which can't be changed at runtime.
+	StaticTokens []string      // API tokens from the config file.
+	SessionTTL   time.Duration // Defaults to DefaultSessionTTL.
+	Proxy        ProxyOpts
+}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Username  string `json:"username"`
+	Method    Method `json:"method"`
+	CSRFToken string `json:"csrf_token,omitempty"` // Required in the X-CSRF-Token header of unsafe requests, for session and proxy auth.
+	sessionID string
+}
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+type Authenticator struct {
+	opts  Opts
+	store *store
+
+	csrfKey []byte // signs CSRF tokens, regenerated on each start along with sessions
+
+	sessions   map[string]session
+	sessionMut sync.Mutex // protects sessions
+
+	logins *loginThrottle
+}
+
+func New(opts Opts) (*Authenticator, error) {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	s, err := newStore(opts.Path, opts.Users)
+	if err != nil {
+		return nil, err
+	}
+	csrfKey := make([]byte, 32)
+	if _, err := rand.Read(csrfKey); err != nil {
+		return nil, err
+	}
+	a := &Authenticator{
+		opts:     opts,
+		store:    s,
+		csrfKey:  csrfKey,
+		sessions: make(map[string]session),
+		logins:   newLoginThrottle(),
+	}
+	if s.empty() && len(opts.StaticTokens) == 0 && opts.Proxy.Header == "" {
+		log.Warn().Msg("No users, API tokens or proxy auth configured, so nobody can sign in. Add auth.users to the config file.")
+	}
+	return a, nil
+}
+
+type identityKey struct{}
+
+// GetIdentity returns the identity Middleware authenticated the request as.
+func GetIdentity(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Middleware rejects requests which aren't authenticated, redirecting
+// browsers to the sign in page. Unsafe requests authenticated by a cookie or
+// proxy must also carry the identity's CSRF token.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := a.authenticate(r)
+		if id == nil {
+			if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/ui/login.html", http.StatusFound)
+				return
+			}
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusUnauthorized, "message": "unauthorized"})
+			return
+		}
+		if id.Method != MethodToken && !safeMethod(r.Method) {
+			provided := r.Header.Get(CSRFHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(id.CSRFToken)) != 1 {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, map[string]interface{}{"code": http.StatusForbidden, "message": "missing or invalid CSRF token"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func (a *Authenticator) authenticate(r *http.Request) *Identity {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.authenticateToken(bearer)
+	}
+	if id := a.authenticateProxy(r); id != nil {
+		return id
+	}
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		return a.authenticateSession(c.Value)
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticateToken(token string) *Identity {
+	for _, static := range a.opts.StaticTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(static)) == 1 {
+			return &Identity{Username: "config", Method: MethodToken}
+		}
+	}
+	if t, ok := a.store.lookupToken(token); ok {
+		return &Identity{Username: t.Username, Method: MethodToken}
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticateProxy(r *http.Request) *Identity {
+	if a.opts.Proxy.Header == "" {
+		return nil
+	}
+	username := r.Header.Get(a.opts.Proxy.Header)
+	if username == "" || !a.trustedProxy(r.RemoteAddr) {
+		return nil
+	}
+	return &Identity{
+		Username:  username,
+		Method:    MethodProxy,
+		CSRFToken: a.csrfToken("proxy:" + username),
+	}
+}
+
+func (a *Authenticator) trustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range a.opts.Proxy.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Authenticator) authenticateSession(id string) *Identity {
+	a.sessionMut.Lock()
+	defer a.sessionMut.Unlock()
+	s, ok := a.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(s.expires) || !a.store.hasUser(s.username) {
+		delete(a.sessions, id)
+		return nil
+	}
+	return &Identity{
+		Username:  s.username,
+		Method:    MethodSession,
+		CSRFToken: a.csrfToken("session:" + id),
+		sessionID: id,
+	}
+}
+
+func (a *Authenticator) csrfToken(subject string) string {
+	mac := hmac.New(sha256.New, a.csrfKey)
+	mac.Write([]byte(subject))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// startSession signs the user in, returning the new session's identity.
+func (a *Authenticator) startSession(w http.ResponseWriter, r *http.Request, username string) (*Identity, error) {
+	id, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(a.opts.SessionTTL)
+
+	a.sessionMut.Lock()
+	now := time.Now()
+	for sid, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, sid)
+		}
+	}
+	a.sessions[id] = session{username: username, expires: expires}
+	a.sessionMut.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return &Identity{
+		Username:  username,
+		Method:    MethodSession,
+		CSRFToken: a.csrfToken("session:" + id),
+		sessionID: id,
+	}, nil
+}
+
+func (a *Authenticator) endSession(w http.ResponseWriter, r *http.Request, id *Identity) {
+	if id.sessionID != "" {
+		a.sessionMut.Lock()
+		delete(a.sessions, id.sessionID)
+		a.sessionMut.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// endUserSessions signs the user out everywhere, e.g. once deleted.
+func (a *Authenticator) endUserSessions(username string) {
+	a.sessionMut.Lock()
+	defer a.sessionMut.Unlock()
+	for id, s := range a.sessions {
+		if s.username == username {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// ParseTrustedProxies parses addresses and CIDR ranges, e.g. 10.0.0.0/8.
+func ParseTrustedProxies(specs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(specs))
+	for _, spec := range specs {
+		if !strings.Contains(spec, "/") {
+			addr, err := netip.ParseAddr(spec)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", spec, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(spec)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", spec, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
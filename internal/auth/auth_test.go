@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "correct horse"
+
+// newTestAuth returns an Authenticator with a configured user, admin, and
+// a router serving it as the server does.
+func newTestAuth(t *testing.T, opts Opts) (*Authenticator, http.Handler) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Path == "" {
+		opts.Path = filepath.Join(t.TempDir(), "auth.json")
+	}
+	opts.Users = append(opts.Users, User{Username: "admin", PasswordHash: string(hash)})
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := chi.NewRouter()
+	router.Post("/auth/login", a.HandleLogin)
+	router.Group(func(r chi.Router) {
+		r.Use(a.Middleware)
+		r.Mount("/api/v1/auth", a.Routes())
+	})
+	return a, router
+}
+
+// request is a request to the test router, authenticated by whichever of
+// its fields are set.
+type request struct {
+	method, path, body string
+	session            *http.Cookie
+	csrf               string
+	token              string
+	remoteAddr         string
+	header             http.Header
+}
+
+func (req request) do(t *testing.T, h http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	method := req.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r := httptest.NewRequest(method, req.path, strings.NewReader(req.body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	if req.session != nil {
+		r.AddCookie(req.session)
+	}
+	if req.csrf != "" {
+		r.Header.Set(CSRFHeader, req.csrf)
+	}
+	if req.token != "" {
+		r.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	if req.remoteAddr != "" {
+		r.RemoteAddr = req.remoteAddr
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func checkStatus(t *testing.T, what string, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("%s = %d %s, want %d", what, w.Code, strings.TrimSpace(w.Body.String()), want)
+	}
+}
+
+// login signs in, returning the session cookie and identity.
+func login(t *testing.T, h http.Handler, username, password string) (*http.Cookie, Identity) {
+	t.Helper()
+	body, _ := json.Marshal(credentials{Username: username, Password: password})
+	w := request{method: http.MethodPost, path: "/auth/login", body: string(body)}.do(t, h)
+	checkStatus(t, "login", w, http.StatusOK)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("login set no session cookie")
+	}
+	id := Identity{}
+	if err := json.NewDecoder(w.Body).Decode(&id); err != nil {
+		t.Fatal(err)
+	}
+	return cookie, id
+}
+
+// createToken creates a token as the identity authenticated by req,
+// returning its ID and secret.
+func createToken(t *testing.T, h http.Handler, req request) (string, string) {
+	t.Helper()
+	req.method, req.path, req.body = http.MethodPost, "/api/v1/auth/tokens", `{"name": "test"}`
+	w := req.do(t, h)
+	checkStatus(t, "create token", w, http.StatusOK)
+	resp := struct {
+		Token  Token  `json:"token"`
+		Secret string `json:"secret"`
+	}{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Token.ID, resp.Secret
+}
+
+func TestSessionAndCSRF(t *testing.T) {
+	_, h := newTestAuth(t, Opts{})
+
+	w := request{path: "/api/v1/auth/session"}.do(t, h)
+	checkStatus(t, "GET /session signed out", w, http.StatusUnauthorized)
+	w = request{path: "/api/v1/auth/session", header: http.Header{"Accept": {"text/html"}}}.do(t, h)
+	checkStatus(t, "GET /session signed out from a browser", w, http.StatusFound)
+
+	cookie, id := login(t, h, "admin", testPassword)
+	if id.Username != "admin" || id.Method != MethodSession || id.CSRFToken == "" {
+		t.Fatalf("login identity = %+v, want admin's session, with a CSRF token", id)
+	}
+	w = request{path: "/api/v1/auth/session", session: cookie}.do(t, h)
+	checkStatus(t, "GET /session", w, http.StatusOK)
+
+	logout := request{method: http.MethodPost, path: "/api/v1/auth/logout", session: cookie}
+	checkStatus(t, "logout without a CSRF token", logout.do(t, h), http.StatusForbidden)
+	logout.csrf = "wrong"
+	checkStatus(t, "logout with the wrong CSRF token", logout.do(t, h), http.StatusForbidden)
+	logout.csrf = id.CSRFToken
+	checkStatus(t, "logout", logout.do(t, h), http.StatusOK)
+
+	w = request{path: "/api/v1/auth/session", session: cookie}.do(t, h)
+	checkStatus(t, "GET /session after logout", w, http.StatusUnauthorized)
+}
+
+func TestTokens(t *testing.T) {
+	a, h := newTestAuth(t, Opts{StaticTokens: []string{"static-token"}})
+	cookie, id := login(t, h, "admin", testPassword)
+	admin := request{session: cookie, csrf: id.CSRFToken}
+
+	tokenID, secret := createToken(t, h, admin)
+	// Tokens need no CSRF token, even for unsafe requests.
+	_, other := createToken(t, h, request{token: secret})
+	checkStatus(t, "GET /session by token", request{path: "/api/v1/auth/session", token: secret}.do(t, h), http.StatusOK)
+
+	revoke := admin
+	revoke.method, revoke.path = http.MethodDelete, "/api/v1/auth/tokens/"+tokenID
+	checkStatus(t, "revoke token", revoke.do(t, h), http.StatusOK)
+	checkStatus(t, "revoke token again", revoke.do(t, h), http.StatusNotFound)
+	checkStatus(t, "GET /session by revoked token", request{path: "/api/v1/auth/session", token: secret}.do(t, h), http.StatusUnauthorized)
+	checkStatus(t, "GET /session by other token", request{path: "/api/v1/auth/session", token: other}.do(t, h), http.StatusOK)
+
+	// Deleting a user revokes their tokens.
+	add := admin
+	add.method, add.path, add.body = http.MethodPost, "/api/v1/auth/users", `{"username": "bob", "password": "bobspassword"}`
+	checkStatus(t, "add bob", add.do(t, h), http.StatusOK)
+	bobCookie, bobID := login(t, h, "bob", "bobspassword")
+	_, bobToken := createToken(t, h, request{session: bobCookie, csrf: bobID.CSRFToken})
+	del := admin
+	del.method, del.path = http.MethodDelete, "/api/v1/auth/users/bob"
+	checkStatus(t, "delete bob", del.do(t, h), http.StatusOK)
+	checkStatus(t, "GET /session by bob's token", request{path: "/api/v1/auth/session", token: bobToken}.do(t, h), http.StatusUnauthorized)
+	checkStatus(t, "GET /session by bob's session", request{path: "/api/v1/auth/session", session: bobCookie}.do(t, h), http.StatusUnauthorized)
+
+	// Identities outside the store can't create tokens, which could
+	// outlive them.
+	checkStatus(t, "GET /session by static token", request{path: "/api/v1/auth/session", token: "static-token"}.do(t, h), http.StatusOK)
+	w := request{method: http.MethodPost, path: "/api/v1/auth/tokens", body: `{"name": "test"}`, token: "static-token"}.do(t, h)
+	checkStatus(t, "create token by static token", w, http.StatusForbidden)
+
+	// Tokens of users removed from the config file are revoked on start.
+	if _, err := New(Opts{Path: a.opts.Path}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(a.opts.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := storeFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Tokens) != 0 {
+		t.Errorf("kept %d tokens of admin, once removed from the config", len(f.Tokens))
+	}
+}
+
+func TestProxyAuth(t *testing.T) {
+	_, h := newTestAuth(t, Opts{Proxy: ProxyOpts{
+		Header:         "Remote-User",
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		user       string
+		want       int
+	}{
+		{"trusted proxy", "10.1.2.3:1234", "carol", http.StatusOK},
+		{"trusted proxy over IPv6", "[::ffff:10.1.2.3]:1234", "carol", http.StatusOK},
+		{"untrusted address", "192.168.1.5:1234", "carol", http.StatusUnauthorized},
+		{"no header", "10.1.2.3:1234", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request{path: "/api/v1/auth/session", remoteAddr: tt.remoteAddr, header: http.Header{}}
+			if tt.user != "" {
+				req.header.Set("Remote-User", tt.user)
+			}
+			w := req.do(t, h)
+			checkStatus(t, "GET /session", w, tt.want)
+			if tt.want != http.StatusOK {
+				return
+			}
+			id := Identity{}
+			if err := json.NewDecoder(w.Body).Decode(&id); err != nil {
+				t.Fatal(err)
+			}
+			if id.Username != tt.user || id.Method != MethodProxy {
+				t.Errorf("identity = %+v, want %s by proxy", id, tt.user)
+			}
+
+			req.method, req.path, req.body = http.MethodPost, "/api/v1/auth/tokens", `{"name": "test"}`
+			checkStatus(t, "create token without a CSRF token", req.do(t, h), http.StatusForbidden)
+			req.csrf = id.CSRFToken
+			checkStatus(t, "create token by proxy", req.do(t, h), http.StatusForbidden)
+		})
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		req  request
+		want int
+	}{
+		{"wrong password", request{body: `{"username": "admin", "password": "wrong password"}`}, http.StatusUnauthorized},
+		{"unknown user", request{body: `{"username": "nobody", "password": "` + testPassword + `"}`}, http.StatusUnauthorized},
+		{"invalid JSON", request{body: `{"username": `}, http.StatusBadRequest},
+		{"form", request{
+			body:   "username=admin&password=" + strings.ReplaceAll(testPassword, " ", "+"),
+			header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, h := newTestAuth(t, Opts{})
+			tt.req.method, tt.req.path = http.MethodPost, "/auth/login"
+			checkStatus(t, "login", tt.req.do(t, h), tt.want)
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	a, h := newTestAuth(t, Opts{})
+	wrong := request{method: http.MethodPost, path: "/auth/login", body: `{"username": "admin", "password": "wrong password"}`}
+	for i := 0; i < loginFreeFailures; i++ {
+		checkStatus(t, "login with the wrong password", wrong.do(t, h), http.StatusUnauthorized)
+	}
+
+	// Even the right password is refused until the delay has passed, from
+	// the same address, or for the same user from elsewhere.
+	right := request{method: http.MethodPost, path: "/auth/login", body: `{"username": "admin", "password": "` + testPassword + `"}`}
+	w := right.do(t, h)
+	checkStatus(t, "login while throttled", w, http.StatusTooManyRequests)
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	elsewhere := right
+	elsewhere.remoteAddr = "192.0.2.2:1234"
+	checkStatus(t, "login from elsewhere while throttled", elsewhere.do(t, h), http.StatusTooManyRequests)
+	other := request{method: http.MethodPost, path: "/auth/login", body: `{"username": "nobody", "password": "wrong password"}`, remoteAddr: "192.0.2.3:1234"}
+	checkStatus(t, "login as someone else from elsewhere", other.do(t, h), http.StatusUnauthorized)
+
+	// As if the delay had passed.
+	a.logins.mut.Lock()
+	for k, f := range a.logins.failures {
+		f.last = f.last.Add(-time.Minute)
+		a.logins.failures[k] = f
+	}
+	a.logins.mut.Unlock()
+	login(t, h, "admin", testPassword)
+	if wait := a.logins.wait(loginKeys(httptest.DefaultRemoteAddr, "admin")); wait != 0 {
+		t.Errorf("wait after signing in = %s, want 0", wait)
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{loginFreeFailures - 1, 0},
+		{loginFreeFailures, loginDelayMin},
+		{loginFreeFailures + 2, 4 * loginDelayMin},
+		{loginFreeFailures + 100, loginDelayMax},
+	}
+	for _, tt := range tests {
+		if got := (loginFailures{count: tt.count}).delay(); got != tt.want {
+			t.Errorf("delay after %d failures = %s, want %s", tt.count, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+)
+
+const maxBodySize = 1 << 16
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// HandleLogin signs a user in with a JSON or form encoded username and
+// password, setting the session cookie. Repeated failures from an address,
+// or for a username, are throttled. It must be served without Middleware.
+func (a *Authenticator) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	c := credentials{}
+	if r.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&c); err != nil {
+			renderError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		c.Username = r.PostFormValue("username")
+		c.Password = r.PostFormValue("password")
+	}
+
+	keys := loginKeys(r.RemoteAddr, c.Username)
+	if wait := a.logins.wait(keys); wait > 0 {
+		log.Warn().Str("username", c.Username).Str("remote_addr", r.RemoteAddr).Dur("wait", wait).Msg("Throttled sign in.")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderError(w, r, http.StatusTooManyRequests, errors.New("too many failed sign ins, try again later"))
+		return
+	}
+	if !a.store.checkPassword(c.Username, c.Password) {
+		a.logins.fail(keys)
+		log.Warn().Str("username", c.Username).Str("remote_addr", r.RemoteAddr).Msg("Failed sign in.")
+		renderError(w, r, http.StatusUnauthorized, errors.New("invalid username or password"))
+		return
+	}
+	a.logins.succeed(keys)
+	id, err := a.startSession(w, r, c.Username)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	log.Info().Str("username", c.Username).Msg("Signed in.")
+	render.JSON(w, r, id)
+}
+
+// Routes serves, behind Middleware:
+//
+//	GET    /session                     the caller's identity, and CSRF token
+//	POST   /logout                      end the caller's session
+//	GET    /users                       users who can sign in
+//	POST   /users                       add a user
+//	PUT    /users/{username}/password   change a user's password
+//	DELETE /users/{username}            remove a user, and their tokens
+//	GET    /tokens                      API tokens
+//	POST   /tokens                      create an API token, returned only once
+//	DELETE /tokens/{id}                 revoke an API token
+func (a *Authenticator) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/session", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, GetIdentity(r.Context()))
+	})
+	router.Post("/logout", func(w http.ResponseWriter, r *http.Request) {
+		a.endSession(w, r, GetIdentity(r.Context()))
+		render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "signed out"})
+	})
+	router.Route("/users", func(r chi.Router) {
+		r.Get("/", a.handleListUsers)
+		r.Post("/", a.handleAddUser)
+		r.Put("/{username}/password", a.handleSetPassword)
+		r.Delete("/{username}", a.handleDeleteUser)
+	})
+	router.Route("/tokens", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			tokens := a.store.listTokens()
+			for i := range tokens {
+				tokens[i].Hash = ""
+			}
+			render.JSON(w, r, map[string]interface{}{"tokens": tokens})
+		})
+		r.Post("/", a.handleCreateToken)
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if err := a.store.deleteToken(chi.URLParam(r, "id")); err != nil {
+				renderStoreError(w, r, err)
+				return
+			}
+			render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "token revoked"})
+		})
+	})
+	return router
+}
+
+func (a *Authenticator) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	type user struct {
+		Username   string `json:"username"`
+		Configured bool   `json:"configured"`
+	}
+	users := []user{}
+	for _, u := range a.store.listUsers() {
+		users = append(users, user{Username: u.Username, Configured: u.Configured})
+	}
+	render.JSON(w, r, map[string]interface{}{"users": users})
+}
+
+func (a *Authenticator) handleAddUser(w http.ResponseWriter, r *http.Request) {
+	c := credentials{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&c); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.store.addUser(c.Username, c.Password); err != nil {
+		renderStoreError(w, r, err)
+		return
+	}
+	log.Info().Str("username", c.Username).Str("by", GetIdentity(r.Context()).Username).Msg("User added.")
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "user added"})
+}
+
+func (a *Authenticator) handleSetPassword(w http.ResponseWriter, r *http.Request) {
+	c := credentials{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&c); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	username := chi.URLParam(r, "username")
+	if err := a.store.setPassword(username, c.Password); err != nil {
+		renderStoreError(w, r, err)
+		return
+	}
+	log.Info().Str("username", username).Str("by", GetIdentity(r.Context()).Username).Msg("Password changed.")
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "password changed"})
+}
+
+func (a *Authenticator) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	if err := a.store.deleteUser(username); err != nil {
+		renderStoreError(w, r, err)
+		return
+	}
+	a.endUserSessions(username)
+	log.Info().Str("username", username).Str("by", GetIdentity(r.Context()).Username).Msg("User deleted.")
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "user deleted"})
+}
+
+func (a *Authenticator) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	t, plaintext, err := a.store.createToken(body.Name, GetIdentity(r.Context()).Username)
+	if err != nil {
+		renderStoreError(w, r, err)
+		return
+	}
+	t.Hash = ""
+	render.JSON(w, r, map[string]interface{}{"token": t, "secret": plaintext})
+}
+
+func renderStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		renderError(w, r, http.StatusNotFound, err)
+	case errors.Is(err, ErrExists), errors.Is(err, ErrConfigured):
+		renderError(w, r, http.StatusConflict, err)
+	case errors.Is(err, ErrNoUser):
+		renderError(w, r, http.StatusForbidden, err)
+	default:
+		renderError(w, r, http.StatusBadRequest, err)
+	}
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	render.Status(r, code)
+	render.JSON(w, r, map[string]interface{}{"code": code, "message": err.Error()})
+}
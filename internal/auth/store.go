@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/rtrox/informer/internal/atomicfile"
+)
+
+const (
+	minPasswordLength = 8
+	tokenPrefix       = "inf_"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrExists          = errors.New("already exists")
+	ErrConfigured      = errors.New("configured in the config file, and can't be changed at runtime")
+	ErrNoUser          = errors.New("API tokens can only be created by users who sign in with a password")
+	ErrInvalidPassword = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"` // bcrypt
+	CreatedAt    time.Time `json:"created_at"`
+	Configured   bool      `json:"-"` // From the config file, so read-only.
+}
+
+// Token is an API token. Only a hash of the token is kept.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`       // The user who created the token. Tokens are revoked along with their user.
+	Hash      string    `json:"hash,omitempty"` // hex encoded SHA-256 of the token
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type storeFile struct {
+	Users  []User  `json:"users"`
+	Tokens []Token `json:"tokens"`
+}
+
+// store holds the users and API tokens, persisting those created at runtime.
+type store struct {
+	path string
+	mut  sync.RWMutex // protects users and tokens
+
+	users  map[string]User
+	tokens map[string]Token // by Hash
+}
+
+func newStore(path string, configured []User) (*store, error) {
+	s := &store{
+		path:   path,
+		users:  make(map[string]User),
+		tokens: make(map[string]Token),
+	}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("auth: %w", err)
+	default:
+		f := storeFile{}
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", path, err)
+		}
+		for _, u := range f.Users {
+			s.users[u.Username] = u
+		}
+		for _, t := range f.Tokens {
+			s.tokens[t.Hash] = t
+		}
+	}
+	for _, u := range configured {
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("auth: user %s: password hash: %w", u.Username, err)
+		}
+		u.Configured = true
+		s.users[u.Username] = u
+	}
+	// Revoke the tokens of users since removed from the config file.
+	revoked := 0
+	for hash, t := range s.tokens {
+		if _, ok := s.users[t.Username]; !ok {
+			delete(s.tokens, hash)
+			revoked++
+		}
+	}
+	if revoked > 0 {
+		log.Warn().Int("revoked", revoked).Msg("Revoked API tokens of users who no longer exist.")
+		if err := s.save(); err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// save persists users and tokens created at runtime. Callers must hold mut.
+func (s *store) save() error {
+	f := storeFile{Users: []User{}, Tokens: []Token{}}
+	for _, u := range s.users {
+		if !u.Configured {
+			f.Users = append(f.Users, u)
+		}
+	}
+	for _, t := range s.tokens {
+		f.Tokens = append(f.Tokens, t)
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.path, b)
+}
+
+func (s *store) empty() bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return len(s.users) == 0 && len(s.tokens) == 0
+}
+
+// dummyHash is compared against when a user doesn't exist, so that sign in
+// takes as long for unknown users as for wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("informer"), bcrypt.DefaultCost)
+
+// checkPassword reports whether password is correct for the user.
+func (s *store) checkPassword(username, password string) bool {
+	s.mut.RLock()
+	u, ok := s.users[username]
+	s.mut.RUnlock()
+	hash := dummyHash
+	if ok {
+		hash = []byte(u.PasswordHash)
+	}
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	return ok && err == nil
+}
+
+func (s *store) hasUser(username string) bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	_, ok := s.users[username]
+	return ok
+}
+
+func (s *store) listUsers() []User {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+func (s *store) addUser(username, password string) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.users[username]; ok {
+		return ErrExists
+	}
+	s.users[username] = User{Username: username, PasswordHash: hash, CreatedAt: time.Now()}
+	return s.save()
+}
+
+func (s *store) setPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	u, ok := s.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.Configured {
+		return ErrConfigured
+	}
+	u.PasswordHash = hash
+	s.users[username] = u
+	return s.save()
+}
+
+// deleteUser removes the user, and any tokens they created.
+func (s *store) deleteUser(username string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	u, ok := s.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.Configured {
+		return ErrConfigured
+	}
+	delete(s.users, username)
+	for hash, t := range s.tokens {
+		if t.Username == username {
+			delete(s.tokens, hash)
+		}
+	}
+	return s.save()
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupToken returns the stored token matching token, if any, and only
+// while the user who created it exists.
+func (s *store) lookupToken(token string) (Token, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	t, ok := s.tokens[hashToken(token)]
+	if !ok {
+		return Token{}, false
+	}
+	_, ok = s.users[t.Username]
+	return t, ok
+}
+
+func (s *store) listTokens() []Token {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// createToken returns a new token, which is only available now. Only users
+// in the store may create tokens, so that they can be revoked along with
+// the user.
+func (s *store) createToken(name, username string) (Token, string, error) {
+	if name == "" {
+		return Token{}, "", fmt.Errorf("token name is required")
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return Token{}, "", err
+	}
+	id, err := randomString(9)
+	if err != nil {
+		return Token{}, "", err
+	}
+	plaintext := tokenPrefix + secret
+	t := Token{
+		ID:        id,
+		Name:      name,
+		Username:  username,
+		Hash:      hashToken(plaintext),
+		CreatedAt: time.Now(),
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, ok := s.users[username]; !ok {
+		return Token{}, "", ErrNoUser
+	}
+	s.tokens[t.Hash] = t
+	return t, plaintext, s.save()
+}
+
+func (s *store) deleteToken(id string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for hash, t := range s.tokens {
+		if t.ID == id {
+			delete(s.tokens, hash)
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
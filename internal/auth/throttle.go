@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	loginFreeFailures = 3 // Failed sign ins allowed before throttling.
+	loginDelayMin     = time.Second
+	loginDelayMax     = 15 * time.Minute
+)
+
+// loginThrottle slows down password guessing. After loginFreeFailures
+// failed sign ins from an address, or for a username, further attempts are
+// refused for a delay which doubles with each failure, up to loginDelayMax.
+// A successful sign in resets it.
+type loginThrottle struct {
+	mut      sync.Mutex
+	failures map[string]loginFailures // by key, see loginKeys
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{failures: make(map[string]loginFailures)}
+}
+
+// loginKeys returns the keys a sign in is throttled by: its remote address,
+// and the username it's for.
+func loginKeys(remoteAddr, username string) []string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return []string{"addr:" + host, "user:" + username}
+}
+
+// delay returns the delay after count failures.
+func (f loginFailures) delay() time.Duration {
+	if f.count < loginFreeFailures {
+		return 0
+	}
+	d := loginDelayMin
+	for i := loginFreeFailures; i < f.count && d < loginDelayMax; i++ {
+		d *= 2
+	}
+	if d > loginDelayMax {
+		d = loginDelayMax
+	}
+	return d
+}
+
+// wait returns how long until a sign in for keys may be attempted, or 0 if
+// it may be now.
+func (t *loginThrottle) wait(keys []string) time.Duration {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range keys {
+		f := t.failures[k]
+		if w := f.last.Add(f.delay()).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+func (t *loginThrottle) fail(keys []string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	now := time.Now()
+	// Forget failures long enough ago that they no longer delay anything.
+	for k, f := range t.failures {
+		if now.Sub(f.last) > loginDelayMax {
+			delete(t.failures, k)
+		}
+	}
+	for _, k := range keys {
+		f := t.failures[k]
+		f.count++
+		f.last = now
+		t.failures[k] = f
+	}
+}
+
+func (t *loginThrottle) succeed(keys []string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	for _, k := range keys {
+		delete(t.failures, k)
+	}
+}
@@ -1,10 +1,20 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/gookit/validate"
+	flag "github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/sink"
 )
 
 // todo: find a better way than passing yaml.Node around
@@ -14,41 +24,321 @@ type SinkSourceConfig struct {
 	Config yaml.Node `yaml:"config"`
 }
 
+// SourceConfig holds a source's own config, along with the options Informer
+// applies around it.
+type SourceConfig struct {
+	SinkSourceConfig `yaml:",inline"`
+	Capture          *CaptureConfig `yaml:"capture,omitempty"` // Write each raw request, and its result, to a directory.
+}
+
+type LifecycleConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+// SinkConfig holds a sink's own config, along with the options Informer
+// applies around it.
+type SinkConfig struct {
+	SinkSourceConfig `yaml:",inline"`
+	Filter           FilterConfig    `yaml:"filter,omitempty"`    // Events routed to this sink. Defaults to every event.
+	Lifecycle        LifecycleConfig `yaml:"lifecycle,omitempty"` // Edit the message posted for a Grab as later events for the same download arrive. Can't be combined with digest, coalesce or schedule.
+	Digest           *DigestConfig   `yaml:"digest,omitempty"`    // Deliver matching events as periodic summaries.
+	Coalesce         *CoalesceConfig `yaml:"coalesce,omitempty"`  // Combine bursts of similar events before delivery.
+	RateLimit        RateLimitConfig `yaml:"rate-limit,omitempty"`
+	Schedule         *ScheduleConfig `yaml:"schedule,omitempty"`  // Only deliver events during these windows.
+	LogLevel         string          `yaml:"log-level,omitempty"` // Overrides log-levels.sinks for this sink.
+}
+
+type DedupConfig struct {
+	Window      time.Duration `yaml:"window"`      // Drop events repeating an earlier fingerprint within this window. Zero disables.
+	Fingerprint []string      `yaml:"fingerprint"` // Event fields identifying an occurrence, e.g. source, title, correlation_id or metadata.quality.
+}
+
+// HistoryConfig records received events, and what became of them.
+type HistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Path      string        `yaml:"path"`       // Defaults to history.db in the data directory.
+	MaxAge    time.Duration `yaml:"max-age"`    // Prune events older than this. Zero keeps events regardless of age.
+	MaxEvents int           `yaml:"max-events"` // Keep only the newest max-events events. Zero keeps any number.
+}
+
+// The shortest admin.token accepted, so a guessable one can't be configured.
+const minAdminTokenLength = 16
+
+// AdminConfig configures the /api/v1 admin API.
+type AdminConfig struct {
+	Token string `yaml:"token"` // Optional static API token, for automation. Tokens can also be created at runtime.
+}
+
+type UserConfig struct {
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password-hash"` // bcrypt, e.g. from `htpasswd -nbB user password`, without the "user:" prefix.
+}
+
+type ProxyAuthConfig struct {
+	Header         string   `yaml:"header"`          // e.g. Remote-User. Disabled if empty.
+	TrustedProxies []string `yaml:"trusted-proxies"` // Addresses or CIDR ranges allowed to set the header.
+}
+
+// AuthConfig configures who can sign in to the web UI and admin API.
+type AuthConfig struct {
+	Users      []UserConfig    `yaml:"users"` // In addition to users added at runtime, and read-only.
+	SessionTTL time.Duration   `yaml:"session-ttl"`
+	Proxy      ProxyAuthConfig `yaml:"proxy"`
+}
+
 type Config struct {
-	QueueSize     int                `yaml:"queue-size" validate:"required"`
-	SinkQueueSize int                `yaml:"sink-queue-size" validate:"required"`
-	LogLevel      string             `yaml:"log-level"` // TODO: build validator
-	LogFormat     string             `yaml:"log-format" validate:"in:console,json"`
-	Interface     string             `yaml:"interface" validate:"required|ip"`
-	Port          int                `yaml:"port" validate:"required"`
-	Sources       []SinkSourceConfig `yaml:"sources"`
-	Sinks         []SinkSourceConfig `yaml:"sinks"`
+	QueueSize     int               `yaml:"queue-size" validate:"required"`
+	SinkQueueSize int               `yaml:"sink-queue-size" validate:"required"`
+	LogLevel      string            `yaml:"log-level"`
+	LogLevels     map[string]string `yaml:"log-levels"` // Levels of the broker, sources and sinks components, overriding log-level.
+	LogFormat     string            `yaml:"log-format" validate:"in:console,json"`
+	Interface     string            `yaml:"interface" validate:"required|ip"`
+	Port          int               `yaml:"port" validate:"required"`
+	Sources       []SourceConfig    `yaml:"sources"`
+	Sinks         []SinkConfig      `yaml:"sinks"`
+	Dedup         DedupConfig       `yaml:"dedup"`
+	DataDir       string            `yaml:"data-dir" validate:"required"` // Where state which must survive restarts is kept.
+	History       HistoryConfig     `yaml:"history"`
+	Encryption    EncryptionConfig  `yaml:"encryption"`
+	Admin         AdminConfig       `yaml:"admin"`
+	Auth          AuthConfig        `yaml:"auth"`
+
+	file string     // The config file read, if any.
+	doc  *yaml.Node // The config as read, with positions for validation errors.
+
+	// Sources and sinks set, or changed, by environment variables or flags,
+	// which the config store won't edit.
+	outsideFile map[entryKey]bool
+
+	offline    bool           // Loaded by LoadConfigOffline.
+	unresolved []*expandError // Values left unread by LoadConfigOffline.
+	warnings   []Problem      // Set by Validate.
 }
 
-func LoadConfig(configFile string) (*Config, error) {
-	c := Config{
-		LogLevel:  "info",
-		LogFormat: "Console",
-		Interface: "0.0.0.0",
-		Port:      8080,
+// DefaultConfigFile is read if it exists, when no config file is named.
+const DefaultConfigFile = "config.yaml"
+
+func defaultConfig() Config {
+	return Config{
+		QueueSize:     10,
+		SinkQueueSize: 10,
+		LogLevel:      "info",
+		LogFormat:     logging.FormatConsole,
+		Interface:     "0.0.0.0",
+		Port:          8080,
+		DataDir:       "data",
+		History: HistoryConfig{
+			MaxAge:    30 * 24 * time.Hour,
+			MaxEvents: 10000,
+		},
 	}
+}
+
+// LoadConfig reads the config from, in increasing precedence: defaults,
+// the config file, INFORMER_* environment variables, and the flags set in
+// flags, which may be nil. configFile defaults to INFORMER_CONFIG, then to
+// DefaultConfigFile if it exists, so that Informer can be configured
+// without a file.
+func LoadConfig(configFile string, flags *flag.FlagSet) (*Config, error) {
+	return loadConfig(configFile, flags, false)
+}
 
+// LoadConfigOffline loads the config as LoadConfig does, but reads neither
+// INFORMER_* environment variables, ${VAR} references without a default,
+// nor keys suffixed _file, which may only exist where Informer is deployed,
+// e.g. to check a config in CI. Validate can't check the values left unread,
+// and lists them in Warnings instead.
+func LoadConfigOffline(configFile string, flags *flag.FlagSet) (*Config, error) {
+	return loadConfig(configFile, flags, true)
+}
+
+func loadConfig(configFile string, flags *flag.FlagSet, offline bool) (*Config, error) {
+	c := defaultConfig()
+
+	required := true
+	if configFile == "" {
+		configFile = configFileFromEnv()
+	}
+	if configFile == "" {
+		configFile, required = DefaultConfigFile, false
+	}
+	doc := yaml.Node{}
 	yamlFile, err := os.ReadFile(configFile)
-	if err != nil {
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !required:
+	case err != nil:
 		return nil, err
+	default:
+		if err := yaml.Unmarshal(yamlFile, &doc); err != nil {
+			return nil, err
+		}
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", configFile)
 	}
 
-	err = yaml.Unmarshal(yamlFile, &c)
-	if err != nil {
+	file := copyNode(root)
+	if !offline {
+		if err := applyEnv(root, os.Environ()); err != nil {
+			return nil, err
+		}
+	}
+	if flags != nil {
+		if err := applyFlags(root, flags); err != nil {
+			return nil, err
+		}
+	}
+	changed := changedEntries(file, root)
+	x := &expansion{offline: offline}
+	if err := x.document(&doc); err != nil {
+		var at *expandError
+		if errors.As(err, &at) && at.node.Line > 0 {
+			return nil, fmt.Errorf("%s:%d:%d: %w", configFile, at.node.Line, at.node.Column, err)
+		}
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	if err := doc.Decode(&c); err != nil {
 		return nil, err
 	}
+	if required || len(yamlFile) > 0 {
+		c.file = configFile
+	}
+	c.doc = &doc
+	c.outsideFile = make(map[entryKey]bool)
+	for item, section := range changed {
+		if name := lookupPath(item, []string{"name"}); name != nil {
+			c.outsideFile[entryKey{section, name.Value}] = true
+		}
+	}
+	c.offline = offline
+	c.unresolved = x.unresolved
 	return &c, nil
 }
 
+// Validate checks the whole config, including each source and sink's own
+// config, returning a *ValidationError listing every problem found, with
+// its position in the config file where known.
 func (c *Config) Validate() error {
+	p := &problems{file: c.file, offline: c.offline}
+	for _, u := range c.unresolved {
+		p.unresolved(u, "")
+	}
+	root := nodeAt(c.doc)
+	checkKnownFields(p, root, reflect.TypeOf(Config{}), "")
+
 	v := validate.Struct(c)
 	if !v.Validate() {
-		return v.Errors
+		t := reflect.TypeOf(Config{})
+		for field, messages := range v.Errors {
+			key := field
+			if f, ok := t.FieldByName(field); ok {
+				key, _ = yamlKey(f)
+			}
+			for _, message := range messages {
+				p.add(nodeAt(root, key), key, "%s", strings.Replace(message, field, key, 1))
+			}
+		}
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		p.add(nodeAt(root, "log-level"), "log-level", "%s", err)
+	}
+	for component, level := range c.LogLevels {
+		path := joinPath("log-levels", component)
+		switch component {
+		case logging.Broker, logging.Sources, logging.Sinks:
+		default:
+			p.add(nodeAt(root, "log-levels", component), path, "unknown component, expected %s, %s or %s", logging.Broker, logging.Sources, logging.Sinks)
+			continue
+		}
+		if _, err := logging.ParseLevel(level); err != nil {
+			p.add(nodeAt(root, "log-levels", component), path, "%s", err)
+		}
+	}
+	if err := event.ValidateFingerprintFields(c.Dedup.Fingerprint); err != nil {
+		p.add(nodeAt(root, "dedup", "fingerprint"), "dedup.fingerprint", "%s", err)
+	}
+	if c.Admin.Token != "" && len(c.Admin.Token) < minAdminTokenLength {
+		p.add(nodeAt(root, "admin", "token"), "admin.token", "must be at least %d characters", minAdminTokenLength)
+	}
+	if _, err := c.Auth.Build(c.Admin, c.DataDir); err != nil {
+		p.add(nodeAt(root, "auth"), "auth", "%s", err)
+	}
+	if c.History.MaxAge < 0 || c.History.MaxEvents < 0 {
+		p.add(nodeAt(root, "history"), "history", "max-age and max-events must not be negative")
+	}
+
+	names := make([]string, len(c.Sources))
+	for i, s := range c.Sources {
+		names[i] = s.Name
+		path := fmt.Sprintf("sources[%d]", i)
+		item := nodeAt(root, "sources", i)
+		sourceRegistry.checkEntry(p, s.SinkSourceConfig, item, path)
+		if s.Capture != nil {
+			if _, err := s.Capture.Build(s.Name, c.DataDir); err != nil {
+				p.add(nodeAt(item, "capture"), joinPath(path, "capture"), "%s", err)
+			}
+		}
+	}
+	sourceRegistry.checkNames(p, names, nodeAt(root, "sources"), "sources")
+
+	names = make([]string, len(c.Sinks))
+	for i, s := range c.Sinks {
+		names[i] = s.Name
+		path := fmt.Sprintf("sinks[%d]", i)
+		item := nodeAt(root, "sinks", i)
+		sinkRegistry.checkEntry(p, s.SinkSourceConfig, item, path)
+		if err := c.validateSinkOptions(s); err != nil {
+			p.add(item, path, "%s", err)
+		}
+	}
+	sinkRegistry.checkNames(p, names, nodeAt(root, "sinks"), "sinks")
+	c.warnings = p.warnings
+	return p.err()
+}
+
+// Warnings lists what the last Validate couldn't check, as values were left
+// unread by LoadConfigOffline.
+func (c *Config) Warnings() []Problem {
+	return c.warnings
+}
+
+// validateSinkOptions checks the options Informer applies around a sink.
+func (c *Config) validateSinkOptions(s SinkConfig) error {
+	if s.LogLevel != "" {
+		if _, err := logging.ParseLevel(s.LogLevel); err != nil {
+			return fmt.Errorf("log-level: %w", err)
+		}
+	}
+	if _, err := s.Filter.Build(); err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+	// The wrappers hold events back, so the sink's messages can't be edited.
+	if s.Lifecycle.Enabled && (s.Digest != nil || s.Coalesce != nil || s.Schedule != nil) {
+		return fmt.Errorf("lifecycle: can't be enabled along with digest, coalesce or schedule")
+	}
+	if s.Digest != nil {
+		if _, err := s.Digest.Build(s.Name, c.DataDir); err != nil {
+			return err
+		}
+	}
+	if s.Coalesce != nil {
+		if _, err := s.Coalesce.Build(); err != nil {
+			return err
+		}
+	}
+	if s.Schedule != nil {
+		opts, err := s.Schedule.Build(s.Name, c.DataDir, nil)
+		if err != nil {
+			return err
+		}
+		if opts.Action == sink.OutOfWindowDigest && s.Digest == nil {
+			return fmt.Errorf("schedule: action digest requires digest to be configured")
+		}
 	}
 	return nil
 }
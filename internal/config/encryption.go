@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rtrox/informer/internal/encryption"
+)
+
+// KeyConfig locates an encryption key: 32 bytes, base64 or hex encoded, e.g.
+// generated with `openssl rand -base64 32`.
+type KeyConfig struct {
+	File string `yaml:"file"` // Path to a file containing the key.
+	Env  string `yaml:"env"`  // Name of an environment variable containing the key.
+}
+
+// EncryptionConfig encrypts event history, digest buffers and held events at
+// rest. To rotate keys, move the current key to previous-keys and configure
+// a new key. Records are encrypted again with the new key on startup, after
+// which the previous key can be removed.
+type EncryptionConfig struct {
+	Key          KeyConfig   `yaml:"key"`
+	PreviousKeys []KeyConfig `yaml:"previous-keys"`
+}
+
+func (k KeyConfig) load() ([]byte, error) {
+	var text string
+	switch {
+	case k.File != "" && k.Env != "":
+		return nil, fmt.Errorf("key file and env are mutually exclusive")
+	case k.File != "":
+		b, err := os.ReadFile(k.File)
+		if err != nil {
+			return nil, fmt.Errorf("key file: %w", err)
+		}
+		text = string(b)
+	case k.Env != "":
+		v, ok := os.LookupEnv(k.Env)
+		if !ok {
+			return nil, fmt.Errorf("key env: %s is not set", k.Env)
+		}
+		text = v
+	default:
+		return nil, fmt.Errorf("key file or env is required")
+	}
+	key, err := encryption.ParseKey(text)
+	if err != nil {
+		if k.File != "" {
+			return nil, fmt.Errorf("key file %s: %w", k.File, err)
+		}
+		return nil, fmt.Errorf("key env %s: %w", k.Env, err)
+	}
+	return key, nil
+}
+
+// Build loads the configured keys. It returns a nil Keyring, leaving data in
+// plaintext, if encryption is not configured.
+func (e EncryptionConfig) Build() (*encryption.Keyring, error) {
+	if e.Key == (KeyConfig{}) && len(e.PreviousKeys) == 0 {
+		return nil, nil
+	}
+	current, err := e.Key.load()
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	var previous [][]byte
+	for i, k := range e.PreviousKeys {
+		key, err := k.load()
+		if err != nil {
+			return nil, fmt.Errorf("encryption: previous-keys[%d]: %w", i, err)
+		}
+		previous = append(previous, key)
+	}
+	keyring, err := encryption.NewKeyring(current, previous...)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	return keyring, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rtrox/informer/internal/encryption"
+)
+
+func TestEncryptionConfig(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := bytes.Repeat([]byte{1}, encryption.KeySize)
+	newKey := bytes.Repeat([]byte{2}, encryption.KeySize)
+	keyFile := filepath.Join(dir, "informer.key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(newKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	badFile := filepath.Join(dir, "bad.key")
+	if err := os.WriteFile(badFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ENCRYPTION_TEST_OLD_KEY", base64.StdEncoding.EncodeToString(oldKey))
+	rotation := EncryptionConfig{
+		Key:          KeyConfig{File: keyFile},
+		PreviousKeys: []KeyConfig{{Env: "ENCRYPTION_TEST_OLD_KEY"}},
+	}
+
+	tests := []struct {
+		name    string
+		conf    EncryptionConfig
+		wantErr string
+	}{
+		{name: "file", conf: EncryptionConfig{Key: KeyConfig{File: keyFile}}},
+		{name: "env", conf: EncryptionConfig{Key: KeyConfig{Env: "ENCRYPTION_TEST_OLD_KEY"}}},
+		{name: "previous keys", conf: rotation},
+		{name: "file and env", conf: EncryptionConfig{Key: KeyConfig{File: keyFile, Env: "ENCRYPTION_TEST_OLD_KEY"}}, wantErr: "mutually exclusive"},
+		{name: "missing file", conf: EncryptionConfig{Key: KeyConfig{File: filepath.Join(dir, "missing.key")}}, wantErr: "key file"},
+		{name: "unset env", conf: EncryptionConfig{Key: KeyConfig{Env: "ENCRYPTION_TEST_UNSET"}}, wantErr: "ENCRYPTION_TEST_UNSET is not set"},
+		{name: "bad key", conf: EncryptionConfig{Key: KeyConfig{File: badFile}}, wantErr: "bad.key: key must be"},
+		{name: "previous keys alone", conf: EncryptionConfig{PreviousKeys: []KeyConfig{{File: keyFile}}}, wantErr: "key file or env is required"},
+		{name: "bad previous key", conf: EncryptionConfig{
+			Key:          KeyConfig{File: keyFile},
+			PreviousKeys: []KeyConfig{{File: badFile}},
+		}, wantErr: "previous-keys[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := tt.conf.Build()
+			checkErr(t, "Build", err, tt.wantErr)
+			if err == nil && k == nil {
+				t.Error("Build = nil Keyring, want one")
+			}
+		})
+	}
+
+	// The previous key still opens what it sealed.
+	previous, err := EncryptionConfig{Key: KeyConfig{Env: "ENCRYPTION_TEST_OLD_KEY"}}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := previous.Seal([]byte("held event"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := rotation.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := rotated.Open(sealed); err != nil || string(opened) != "held event" {
+		t.Errorf("Open(sealed with the previous key) = %q, %v", opened, err)
+	}
+}
+
+func TestEncryptionConfigDisabled(t *testing.T) {
+	k, err := EncryptionConfig{}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != nil {
+		t.Fatal("Build = a Keyring, want nil with no keys configured")
+	}
+	// Left in plaintext.
+	sealed, err := k.Seal([]byte("held event"))
+	if err != nil || string(sealed) != "held event" {
+		t.Errorf("Seal = %q, %v, want the plaintext", sealed, err)
+	}
+}
@@ -0,0 +1,451 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables which configure Informer.
+// Keys are upper cased, with hyphens replaced and levels joined by
+// underscores, e.g. INFORMER_QUEUE_SIZE or INFORMER_HISTORY_MAX_AGE. Lists
+// of scalars are comma separated. Sources, sinks and other lists of objects
+// are indexed from 0, e.g. INFORMER_SINKS_0_TYPE. The config of a source or
+// sink may be given whole as YAML or JSON, e.g. INFORMER_SINKS_0_CONFIG, or
+// key by key, e.g. INFORMER_SINKS_0_CONFIG_WEBHOOK_URL, with __ separating
+// nested keys.
+const EnvPrefix = "INFORMER"
+
+// EnvConfigFile names the config file, like --config.
+const EnvConfigFile = EnvPrefix + "_CONFIG"
+
+var yamlNodeType = reflect.TypeOf(yaml.Node{})
+
+// envKey converts a YAML key to its environment variable form.
+func envKey(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// yamlKey returns the YAML key of a struct field, and whether it's inlined.
+// The key is empty for fields YAML ignores.
+func yamlKey(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, false
+}
+
+// envOverlay builds a config document from environment variables, to be
+// merged over the config file. used records the variables consumed.
+type envOverlay struct {
+	env  map[string]string
+	used map[string]bool
+}
+
+func newEnvOverlay(environ []string) *envOverlay {
+	e := &envOverlay{env: make(map[string]string), used: make(map[string]bool)}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix+"_") {
+			e.env[k] = v
+		}
+	}
+	e.used[EnvConfigFile] = true
+	return e
+}
+
+// lookup returns the named variable, marking it used.
+func (e *envOverlay) lookup(name string) (string, bool) {
+	v, ok := e.env[name]
+	if ok {
+		e.used[name] = true
+	}
+	return v, ok
+}
+
+// unused returns the variables which configured nothing, sorted.
+func (e *envOverlay) unused() []string {
+	names := []string{}
+	for k := range e.env {
+		if !e.used[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// node returns the values of type t set by variables prefixed name, or nil
+// if none are.
+func (e *envOverlay) node(t reflect.Type, name string) (*yaml.Node, error) {
+	if t == yamlNodeType {
+		return e.blob(name)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return e.node(t.Elem(), name)
+	case reflect.Struct:
+		return e.mapping(t, name)
+	case reflect.Slice:
+		elem := t.Elem()
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			return e.indexed(elem, name)
+		}
+		return e.list(name)
+	case reflect.Map:
+		return e.whole(name)
+	default:
+		if v, ok := e.lookup(name); ok {
+			return &yaml.Node{Kind: yaml.ScalarNode, Value: v}, nil
+		}
+		return nil, nil
+	}
+}
+
+// whole parses a variable holding a YAML or JSON value, if set.
+func (e *envOverlay) whole(name string) (*yaml.Node, error) {
+	v, ok := e.lookup(name)
+	if !ok {
+		return nil, nil
+	}
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(v), &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+func (e *envOverlay) mapping(t reflect.Type, name string) (*yaml.Node, error) {
+	n, err := e.whole(name)
+	if err != nil {
+		return nil, err
+	}
+	fields := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, inline := yamlKey(f)
+		if inline {
+			child, err := e.node(f.Type, name)
+			if err != nil {
+				return nil, err
+			}
+			if child != nil {
+				fields.Content = append(fields.Content, child.Content...)
+			}
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		child, err := e.node(f.Type, name+"_"+envKey(key))
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			fields.Content = append(fields.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		}
+	}
+	return mergeOverlay(n, fields), nil
+}
+
+func (e *envOverlay) list(name string) (*yaml.Node, error) {
+	v, ok := e.env[name]
+	if !ok || strings.HasPrefix(strings.TrimSpace(v), "[") {
+		return e.whole(name)
+	}
+	e.used[name] = true
+	n := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+	}
+	return n, nil
+}
+
+// indexed returns a sequence of the objects set by name_0_..., name_1_...,
+// with empty mappings for any indexes skipped, which merge as no-ops.
+func (e *envOverlay) indexed(elem reflect.Type, name string) (*yaml.Node, error) {
+	n, err := e.whole(name)
+	if err != nil {
+		return nil, err
+	}
+	indexes := map[int]bool{}
+	for k := range e.env {
+		rest, ok := strings.CutPrefix(k, name+"_")
+		if !ok {
+			continue
+		}
+		digits, _, _ := strings.Cut(rest, "_")
+		if i, err := strconv.Atoi(digits); err == nil && i >= 0 {
+			indexes[i] = true
+		}
+	}
+	if len(indexes) == 0 {
+		return n, nil
+	}
+	max := 0
+	for i := range indexes {
+		if i > max {
+			max = i
+		}
+	}
+	items := &yaml.Node{Kind: yaml.SequenceNode, Tag: indexedTag}
+	for i := 0; i <= max; i++ {
+		item, err := e.node(elem, fmt.Sprintf("%s_%d", name, i))
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			item = &yaml.Node{Kind: yaml.MappingNode}
+		}
+		items.Content = append(items.Content, item)
+	}
+	return mergeOverlay(n, items), nil
+}
+
+// blob returns a source or sink's config, whose schema depends on its type,
+// so keys are lower cased with __ separating nested keys, and later renamed
+// to match the type's config by renameEnvConfigKeys.
+func (e *envOverlay) blob(name string) (*yaml.Node, error) {
+	n, err := e.whole(name)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for k := range e.env {
+		if strings.HasPrefix(k, name+"_") {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return n, nil
+	}
+	sort.Strings(keys)
+	fields := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range keys {
+		v, _ := e.lookup(k)
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(k, name+"_")), "__")
+		setPath(fields, path, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+	}
+	return mergeOverlay(n, fields), nil
+}
+
+// setPath sets the value at path within the mapping m, adding mappings as
+// needed.
+func setPath(m *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			m.Content[i+1] = value
+		} else {
+			if m.Content[i+1].Kind != yaml.MappingNode {
+				m.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode}
+			}
+			setPath(m.Content[i+1], path[1:], value)
+		}
+		return
+	}
+	child := value
+	if len(path) > 1 {
+		child = &yaml.Node{Kind: yaml.MappingNode}
+		setPath(child, path[1:], value)
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}, child)
+}
+
+// indexedTag marks sequences of objects from indexed variables, which merge
+// item by item rather than replacing the whole list.
+const indexedTag = "!informer/indexed"
+
+// mergeOverlay merges src over dst, either of which may be nil, returning
+// the result.
+func mergeOverlay(dst, src *yaml.Node) *yaml.Node {
+	switch {
+	case src == nil || (src.Kind == yaml.MappingNode && len(src.Content) == 0):
+		return dst
+	case dst == nil:
+		return src
+	}
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			k, v := src.Content[i], src.Content[i+1]
+			found := false
+			for j := 0; j+1 < len(dst.Content); j += 2 {
+				if dst.Content[j].Value == k.Value {
+					dst.Content[j+1] = mergeOverlay(dst.Content[j+1], v)
+					found = true
+					break
+				}
+			}
+			if !found {
+				dst.Content = append(dst.Content, k, mergeOverlay(nil, v))
+			}
+		}
+		return dst
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode && src.Tag == indexedTag:
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				dst.Content[i] = mergeOverlay(dst.Content[i], item)
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+		return dst
+	}
+	if src.Tag == indexedTag {
+		src.Tag = ""
+	}
+	return src
+}
+
+// applyEnv merges the INFORMER_* environment variables over doc's root
+// mapping, warning about any which don't configure anything.
+func applyEnv(root *yaml.Node, environ []string) error {
+	e := newEnvOverlay(environ)
+	overlay, err := e.node(reflect.TypeOf(Config{}), EnvPrefix)
+	if err != nil {
+		return err
+	}
+	mergeOverlay(root, overlay)
+	clearIndexedTags(root)
+	renameEnvConfigKeys(root)
+	for _, name := range e.unused() {
+		log.Warn().Str("variable", name).Msg("Ignoring environment variable which matches no config key.")
+	}
+	return nil
+}
+
+// entryKey identifies a source or sink by its section, sources or sinks,
+// and name.
+type entryKey struct {
+	section string
+	name    string
+}
+
+// changedEntries returns the sources and sinks in root which differ from
+// those at the same index in file, i.e. which were set, or changed, by
+// environment variables or flags, mapped to their section.
+func changedEntries(file, root *yaml.Node) map[*yaml.Node]string {
+	changed := make(map[*yaml.Node]string)
+	for _, section := range []string{"sources", "sinks"} {
+		items := lookupPath(root, []string{section})
+		if items == nil {
+			continue
+		}
+		var fileItems []*yaml.Node
+		if f := lookupPath(file, []string{section}); f != nil {
+			fileItems = f.Content
+		}
+		for i, item := range items.Content {
+			if i >= len(fileItems) || !sameNode(item, fileItems[i]) {
+				changed[item] = section
+			}
+		}
+	}
+	return changed
+}
+
+// sameNode reports whether a and b hold the same values, ignoring their
+// positions and styles.
+func sameNode(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Tag != b.Tag || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !sameNode(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// renameEnvConfigKeys renames the keys of source and sink config set by
+// environment variables, which are lower cased with underscores, to the
+// keys of the type's config, e.g. api_key to api-key.
+func renameEnvConfigKeys(root *yaml.Node) {
+	for section, r := range map[string]registry{"sources": sourceRegistry, "sinks": sinkRegistry} {
+		items := lookupPath(root, []string{section})
+		if items == nil {
+			continue
+		}
+		for _, item := range items.Content {
+			typ, conf := lookupPath(item, []string{"type"}), lookupPath(item, []string{"config"})
+			if typ == nil || conf == nil {
+				continue
+			}
+			if sample := r.sample(typ.Value); sample != nil {
+				renameEnvKeys(conf, reflect.TypeOf(sample))
+			}
+		}
+	}
+}
+
+func renameEnvKeys(n *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := structFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if _, ok := fields[k.Value]; !ok && k.Line == 0 {
+				for key := range fields {
+					if envKey(key) == envKey(k.Value) {
+						k.Value = key
+					}
+				}
+			}
+			if ft, ok := fields[k.Value]; ok {
+				renameEnvKeys(n.Content[i+1], ft)
+			}
+		}
+	case reflect.Map:
+		for i := 1; i < len(n.Content); i += 2 {
+			renameEnvKeys(n.Content[i], t.Elem())
+		}
+	}
+}
+
+func clearIndexedTags(n *yaml.Node) {
+	if n.Tag == indexedTag {
+		n.Tag = ""
+	}
+	for _, c := range n.Content {
+		clearIndexedTags(c)
+	}
+}
+
+// configFileFromEnv returns the config file named by INFORMER_CONFIG, if any.
+func configFileFromEnv() string {
+	return os.Getenv(EnvConfigFile)
+}
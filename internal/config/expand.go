@@ -0,0 +1,199 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/redact"
+)
+
+// fileSuffix marks a key whose value is read from a file, e.g.
+// api-key_file: /run/secrets/sonarr is read as api-key: <file contents>.
+const fileSuffix = "_file"
+
+// envVar matches ${VAR} and ${VAR:-default}. $${...} escapes a literal ${...}.
+var envVar = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandNode returns a copy of n with ${VAR} and ${VAR:-default} replaced
+// by environment variables in scalar values, and keys suffixed _file
+// replaced by the unsuffixed key, with the contents of the named file.
+// Values read from files, and from the environment for secret looking keys,
+// are registered with redact.
+func ExpandNode(n yaml.Node) (yaml.Node, error) {
+	return (&expansion{}).copy(n)
+}
+
+// expansion expands config values. Offline, it reads neither the
+// environment nor files, which may only exist where Informer is deployed:
+// ${VAR:-default} takes its default, and values which can't be expanded
+// without reading are left null, and listed in unresolved.
+type expansion struct {
+	offline    bool
+	unresolved []*expandError
+}
+
+// copy returns an expanded copy of n.
+func (x *expansion) copy(n yaml.Node) (yaml.Node, error) {
+	c := copyNode(&n)
+	if err := x.node(c, "", nil); err != nil {
+		return yaml.Node{}, err
+	}
+	return *c, nil
+}
+
+// unresolve leaves n null, as it can't be expanded offline.
+func (x *expansion) unresolve(n *yaml.Node, format string, args ...interface{}) {
+	x.unresolved = append(x.unresolved, &expandError{n, fmt.Errorf(format, args...)})
+	*n = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Line: n.Line, Column: n.Column}
+}
+
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	if n.Content != nil {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = copyNode(child)
+		}
+	}
+	return &c
+}
+
+// node expands n in place. key is the mapping key n is the value of, if
+// any. Nodes in skip are left as they are.
+func (x *expansion) node(n *yaml.Node, key string, skip map[*yaml.Node]bool) error {
+	if skip[n] {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := x.node(c, key, skip); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		return x.mapping(n, skip)
+	case yaml.ScalarNode:
+		return x.scalar(n, key)
+	}
+	return nil
+}
+
+func (x *expansion) mapping(n *yaml.Node, skip map[*yaml.Node]bool) error {
+	keys := make(map[string]bool, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys[n.Content[i].Value] = true
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		base, ok := strings.CutSuffix(k.Value, fileSuffix)
+		if !ok || base == "" || v.Kind != yaml.ScalarNode {
+			if err := x.node(v, k.Value, skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if keys[base] {
+			return &expandError{k, fmt.Errorf("%s and %s are both set", base, k.Value)}
+		}
+		if x.offline {
+			k.Value = base
+			x.unresolve(v, "%s%s is read from a file, which isn't read offline", base, fileSuffix)
+			continue
+		}
+		if err := x.scalar(v, k.Value); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(v.Value)
+		if err != nil {
+			return &expandError{k, fmt.Errorf("%s: %w", k.Value, err)}
+		}
+		secret := strings.TrimRight(string(b), "\r\n")
+		redact.Register(secret)
+		k.Value = base
+		*v = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: secret, Line: v.Line, Column: v.Column}
+	}
+	return nil
+}
+
+func (x *expansion) scalar(n *yaml.Node, key string) error {
+	if !strings.Contains(n.Value, "${") {
+		return nil
+	}
+	var missing []string
+	expanded := envVar.ReplaceAllStringFunc(n.Value, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		m := envVar.FindStringSubmatch(match)
+		value, ok := "", false
+		if !x.offline {
+			value, ok = os.LookupEnv(m[1])
+		}
+		if !ok || (value == "" && m[2] != "") {
+			if m[2] == "" {
+				missing = append(missing, m[1])
+			}
+			value = m[3]
+		}
+		if redact.IsSecretKey(key) {
+			redact.Register(value)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		if x.offline {
+			x.unresolve(n, "environment variable %s isn't read offline", strings.Join(missing, ", "))
+			return nil
+		}
+		return &expandError{n, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))}
+	}
+	n.Value = expanded
+	if n.Style == 0 {
+		// Let plain scalars resolve to the type of their new value, e.g. an int.
+		n.Tag = ""
+	}
+	return nil
+}
+
+// document expands the config file in place, apart from the config of
+// each source and sink, which is kept as written so that the config store
+// never persists secrets. Those are expanded as they're built, by ExpandNode.
+func (x *expansion) document(doc *yaml.Node) error {
+	skip := make(map[*yaml.Node]bool)
+	if len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if k := root.Content[i].Value; k != "sources" && k != "sinks" {
+				continue
+			}
+			for _, item := range root.Content[i+1].Content {
+				for j := 0; j+1 < len(item.Content); j += 2 {
+					if item.Content[j].Value == "config" {
+						skip[item.Content[j+1]] = true
+					}
+				}
+			}
+		}
+	}
+	return x.node(doc, "", skip)
+}
+
+// expandError is an error expanding node. Its position is left to callers,
+// as ExpandNode's may already report that of the enclosing config.
+type expandError struct {
+	node *yaml.Node
+	err  error
+}
+
+func (e *expandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *expandError) Unwrap() error {
+	return e.err
+}
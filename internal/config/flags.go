@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// flagSetting is a config key which can be set by a command line flag.
+type flagSetting struct {
+	path []string // YAML keys
+	kind reflect.Kind
+	typ  reflect.Type
+}
+
+func (s flagSetting) flagName() string {
+	return strings.Join(s.path, "-")
+}
+
+func (s flagSetting) envName() string {
+	name := EnvPrefix
+	for _, key := range s.path {
+		name += "_" + envKey(key)
+	}
+	return name
+}
+
+// flagSettings lists the scalars and lists of scalars within t, e.g.
+// queue-size or history.max-age. Sources, sinks and other lists of objects
+// can't be set by flags.
+func flagSettings(t reflect.Type, path []string) []flagSetting {
+	settings := []flagSetting{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _ := yamlKey(f)
+		if key == "" || f.Type == yamlNodeType {
+			continue
+		}
+		p := append(append([]string{}, path...), key)
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			settings = append(settings, flagSettings(f.Type, p)...)
+		case reflect.Slice:
+			if f.Type.Elem().Kind() != reflect.Struct {
+				settings = append(settings, flagSetting{path: p, kind: reflect.Slice, typ: f.Type})
+			}
+		case reflect.Pointer, reflect.Map:
+		default:
+			settings = append(settings, flagSetting{path: p, kind: f.Type.Kind(), typ: f.Type})
+		}
+	}
+	return settings
+}
+
+// RegisterFlags adds a flag to fs for each config key flagSettings finds.
+// Flags take precedence over environment variables, which take precedence
+// over the config file.
+func RegisterFlags(fs *flag.FlagSet) {
+	defaults := yaml.Node{}
+	_ = defaults.Encode(defaultConfig())
+	for _, s := range flagSettings(reflect.TypeOf(Config{}), nil) {
+		usage := fmt.Sprintf("Sets %s (env %s)", strings.Join(s.path, "."), s.envName())
+		def := lookupPath(&defaults, s.path)
+		switch s.kind {
+		case reflect.Slice:
+			var items []string
+			if def != nil {
+				for _, item := range def.Content {
+					items = append(items, item.Value)
+				}
+			}
+			fs.StringSlice(s.flagName(), items, usage)
+		case reflect.Bool:
+			var b bool
+			decodeDefault(def, &b)
+			fs.Bool(s.flagName(), b, usage)
+		case reflect.Int, reflect.Int64:
+			if s.typ == durationType {
+				var d time.Duration
+				decodeDefault(def, &d)
+				fs.Duration(s.flagName(), d, usage)
+				break
+			}
+			var n int64
+			decodeDefault(def, &n)
+			if s.kind == reflect.Int {
+				fs.Int(s.flagName(), int(n), usage)
+			} else {
+				fs.Int64(s.flagName(), n, usage)
+			}
+		default:
+			fs.String(s.flagName(), valueOf(def), usage)
+		}
+	}
+}
+
+// applyFlags merges the flags set on the command line over doc's root
+// mapping. Flags left at their defaults are ignored, so that they don't
+// override the config file or environment.
+func applyFlags(root *yaml.Node, fs *flag.FlagSet) error {
+	overlay := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range flagSettings(reflect.TypeOf(Config{}), nil) {
+		f := fs.Lookup(s.flagName())
+		if f == nil || !f.Changed {
+			continue
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Value.String()}
+		if s.kind == reflect.Slice {
+			items, err := fs.GetStringSlice(s.flagName())
+			if err != nil {
+				return err
+			}
+			value = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range items {
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+		}
+		setPath(overlay, s.path, value)
+	}
+	mergeOverlay(root, overlay)
+	return nil
+}
+
+// lookupPath returns the value at path within the document or mapping n.
+func lookupPath(n *yaml.Node, path []string) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	if len(path) == 0 {
+		return n
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == path[0] {
+			return lookupPath(n.Content[i+1], path[1:])
+		}
+	}
+	return nil
+}
+
+// decodeDefault decodes the default value n into v, leaving v zero if
+// there's no default.
+func decodeDefault(n *yaml.Node, v interface{}) {
+	if n != nil {
+		_ = n.Decode(v)
+	}
+}
+
+func valueOf(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return ""
+	}
+	switch n.Value {
+	case "0", "0s", "false":
+		return "" // so that --help doesn't list zero defaults
+	}
+	return n.Value
+}
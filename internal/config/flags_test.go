@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("informer", flag.ContinueOnError)
+	RegisterFlags(fs)
+	for name, want := range map[string]string{
+		"port":                       "int",
+		"queue-size":                 "int",
+		"history-enabled":            "bool",
+		"dedup-window":               "duration",
+		"dedup-fingerprint":          "stringSlice",
+		"log-level":                  "string",
+		"auth-proxy-trusted-proxies": "stringSlice",
+	} {
+		f := fs.Lookup(name)
+		if f == nil {
+			t.Errorf("no --%s flag", name)
+			continue
+		}
+		if got := f.Value.Type(); got != want {
+			t.Errorf("--%s is a %s, want %s", name, got, want)
+		}
+	}
+	if err := fs.Parse([]string{"--port", "http"}); err == nil {
+		t.Error("--port http parsed, want an error")
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 9000\nqueue-size: 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("informer", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"--port", "9090", "--history-enabled", "--dedup-window", "5m"}); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := LoadConfig(path, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Port != 9090 {
+		t.Errorf("Port = %d, want the flag's 9090", conf.Port)
+	}
+	if conf.QueueSize != 20 {
+		t.Errorf("QueueSize = %d, want the file's 20, as the flag wasn't set", conf.QueueSize)
+	}
+	if !conf.History.Enabled {
+		t.Error("History.Enabled = false, want true")
+	}
+	if conf.Dedup.Window != 5*time.Minute {
+		t.Errorf("Dedup.Window = %s, want 5m", conf.Dedup.Window)
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/redact"
+)
+
+// Redacted returns the config as it's used, with each source and sink's
+// config expanded, and secrets redacted, suitable for display.
+func (c *Config) Redacted() (*yaml.Node, error) {
+	n := &yaml.Node{}
+	if err := n.Encode(c); err != nil {
+		return nil, err
+	}
+	for _, section := range []string{"sources", "sinks"} {
+		items := lookupPath(n, []string{section})
+		if items == nil {
+			continue
+		}
+		for _, item := range items.Content {
+			conf := lookupPath(item, []string{"config"})
+			if conf == nil {
+				continue
+			}
+			// Left as written if it can't be expanded, e.g. for a missing
+			// secret file.
+			if expanded, err := ExpandNode(*conf); err == nil {
+				*conf = expanded
+			}
+		}
+	}
+	redact.Node(n)
+	return n, nil
+}
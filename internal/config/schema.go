@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envRef matches values which are expanded from the environment, allowing
+// them in place of numbers and booleans.
+var envRef = map[string]interface{}{"type": "string", "pattern": `\$\{`}
+
+// Schema returns a JSON Schema of the config file, including the config of
+// each registered source and sink type, e.g. for editor autocompletion.
+func Schema() map[string]interface{} {
+	s := schemaOf(reflect.TypeOf(Config{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Informer config"
+	props := s["properties"].(map[string]interface{})
+	props["sources"] = entriesSchema(sourceRegistry, reflect.TypeOf(SourceConfig{}))
+	props["sinks"] = entriesSchema(sinkRegistry, reflect.TypeOf(SinkConfig{}))
+	return s
+}
+
+// entriesSchema describes a list of sources or sinks, each of whose config
+// is described according to its type.
+func entriesSchema(r registry, t reflect.Type) map[string]interface{} {
+	item := schemaOf(t)
+	item["required"] = []string{"name", "type"}
+	types := r.types()
+	item["properties"].(map[string]interface{})["type"] = map[string]interface{}{"type": "string", "enum": types}
+	conditions := []interface{}{}
+	for _, typ := range types {
+		config := map[string]interface{}{}
+		if sample := r.sample(typ); sample != nil {
+			config = schemaOf(reflect.TypeOf(sample))
+		}
+		conditions = append(conditions, map[string]interface{}{
+			"if":   map[string]interface{}{"properties": map[string]interface{}{"type": map[string]interface{}{"const": typ}}},
+			"then": map[string]interface{}{"properties": map[string]interface{}{"config": config}},
+		})
+	}
+	item["allOf"] = conditions
+	return map[string]interface{}{"type": "array", "items": item}
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]interface{}{
+			"type":        "string",
+			"description": "A duration, e.g. 90s, 10m or 24h.",
+		}
+	}
+	if t == yamlNodeType || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for key, ft := range structFields(t) {
+			props[key] = schemaOf(ft)
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			// Any key may instead be read from a file, e.g. api-key_file.
+			"patternProperties":    map[string]interface{}{fileSuffix + "$": map[string]interface{}{"type": "string"}},
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "boolean"}, envRef}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "integer"}, envRef}}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "number"}, envRef}}
+	}
+	return map[string]interface{}{}
+}
@@ -1,25 +1,163 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/rs/zerolog/log"
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/logging"
 	"github.com/rtrox/informer/internal/sink"
 	_ "github.com/rtrox/informer/internal/sink/sinks"
+	"gopkg.in/yaml.v3"
 )
 
-func UpdateSinkManagerConfig(manager *sink.SinkManager, conf []SinkSourceConfig) {
-	sinks := make(map[string]sink.Sink)
-	for _, c := range conf {
-		sinks[c.Name] = sink.MakeSink(c.Type, c.Config)
-		log.Info().Str("name", c.Name).Str("type", c.Type).Msg("Registered sink")
+// UpdateSinkManagerConfig builds the configured sinks, and hands them to
+// manager. keyring seals any events the sinks persist, and may be nil. If
+// any sink can't be built, manager is left unchanged.
+func UpdateSinkManagerConfig(manager *sink.SinkManager, conf *Config, keyring *encryption.Keyring) error {
+	sinks, err := BuildSinks(conf, keyring)
+	if err != nil {
+		return err
 	}
 	manager.UpdateSinks(sinks)
+	return nil
 }
 
-func ValidateSinkConfigs(conf []SinkSourceConfig) error {
-	for _, c := range conf {
-		if err := sink.ValidateConfig(c.Type, c.Config); err != nil {
-			return err
+// BuildSinks builds the configured sinks, by name. If any sink can't be
+// built, those which were are closed, and the errors returned.
+func BuildSinks(conf *Config, keyring *encryption.Keyring) (map[string]sink.Entry, error) {
+	sinks := make(map[string]sink.Entry)
+	var errs []error
+	for _, c := range conf.Sinks {
+		if _, ok := sinks[c.Name]; ok {
+			errs = append(errs, fmt.Errorf("sink %s: duplicate name", c.Name))
+			continue
 		}
+		entry, err := makeSinkEntry(c, conf, keyring)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", c.Name, err))
+			continue
+		}
+		sinks[c.Name] = entry
 	}
-	return nil
+	if len(errs) > 0 {
+		closeSinks(sinks)
+		return nil, errors.Join(errs...)
+	}
+	for name, entry := range sinks {
+		log.Info().Str("name", name).Str("type", entry.Type).Msg("Registered sink")
+	}
+	return sinks, nil
+}
+
+// closeSinks releases sinks which were built, but won't be used.
+func closeSinks(sinks map[string]sink.Entry) {
+	for _, entry := range sinks {
+		entry.Sink.Done()
+	}
+}
+
+// makeSinkEntry builds a sink, wrapped as configured, along with its
+// processor options.
+func makeSinkEntry(c SinkConfig, conf *Config, keyring *encryption.Keyring) (sink.Entry, error) {
+	filter, err := c.Filter.Build()
+	if err != nil {
+		return sink.Entry{}, fmt.Errorf("filter: %w", err)
+	}
+	node, err := ExpandNode(c.Config)
+	if err != nil {
+		return sink.Entry{}, err
+	}
+	s, err := sink.MakeSink(c.Type, node, sink.Opts{Log: logging.For(logging.Sink(c.Name))})
+	if err != nil {
+		return sink.Entry{}, err
+	}
+	var digest *sink.Digest
+	if c.Digest != nil {
+		opts, err := c.Digest.Build(c.Name, conf.DataDir)
+		if err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		opts.Keyring = keyring
+		if digest, err = sink.NewDigest(s, opts); err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		s = digest
+	}
+	if c.Coalesce != nil {
+		opts, err := c.Coalesce.Build()
+		if err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		coalescer, err := sink.NewCoalescer(s, opts)
+		if err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		s = coalescer
+	}
+	if c.Schedule != nil {
+		opts, err := c.Schedule.Build(c.Name, conf.DataDir, digest)
+		if err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		opts.Keyring = keyring
+		windowed, err := sink.NewWindowedSink(s, opts)
+		if err != nil {
+			s.Done()
+			return sink.Entry{}, err
+		}
+		s = windowed
+	}
+	config, err := yaml.Marshal(c)
+	if err != nil {
+		s.Done()
+		return sink.Entry{}, err
+	}
+	return sink.Entry{
+		Type:   c.Type,
+		Sink:   s,
+		Config: string(config),
+		Options: sink.Options{
+			Filter: filter,
+			Lifecycle: sink.LifecycleOpts{
+				Enabled: c.Lifecycle.Enabled,
+				TTL:     c.Lifecycle.TTL,
+			},
+			RateLimit: c.RateLimit.Build(),
+		},
+	}, nil
+}
+
+// BuildSink builds the named sink alone, without its digest, coalesce or
+// schedule, which would hold events back, for delivering events directly,
+// e.g. from the command line. The sink's filter is returned alongside.
+func BuildSink(conf *Config, name string) (sink.Sink, sink.Filter, error) {
+	for _, c := range conf.Sinks {
+		if c.Name != name {
+			continue
+		}
+		if err := validateSink(c.SinkSourceConfig); err != nil {
+			return nil, sink.Filter{}, err
+		}
+		filter, err := c.Filter.Build()
+		if err != nil {
+			return nil, sink.Filter{}, fmt.Errorf("sink %s: filter: %w", name, err)
+		}
+		node, err := ExpandNode(c.Config)
+		if err != nil {
+			return nil, sink.Filter{}, fmt.Errorf("sink %s: %w", name, err)
+		}
+		s, err := sink.MakeSink(c.Type, node, sink.Opts{Log: logging.For(logging.Sink(name))})
+		if err != nil {
+			return nil, sink.Filter{}, fmt.Errorf("sink %s: %w", name, err)
+		}
+		return s, filter, nil
+	}
+	return nil, sink.Filter{}, fmt.Errorf("sink %s: %w", name, ErrNotFound)
 }
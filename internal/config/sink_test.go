@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// entryConfig returns the source or sink of type typ with conf, in YAML.
+func entryConfig(t *testing.T, typ string, conf string) SinkSourceConfig {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(conf), &doc); err != nil {
+		t.Fatal(err)
+	}
+	c := SinkSourceConfig{Name: "test", Type: typ}
+	if len(doc.Content) == 1 {
+		c.Config = *doc.Content[0]
+	}
+	return c
+}
+
+// entryTest is a source or sink config, and the error validating it, or
+// building it, should return, if any.
+type entryTest struct {
+	name    string
+	typ     string
+	config  string
+	wantErr string
+	built   bool // whether building it, without validating it first, fails too
+}
+
+func checkErr(t *testing.T, what string, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%s = %v, want no error", what, err)
+	case want != "" && err == nil:
+		t.Errorf("%s succeeded, want an error containing %q", what, want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("%s = %v, want an error containing %q", what, err, want)
+	}
+}
+
+func TestSinkConfig(t *testing.T) {
+	tests := []entryTest{
+		{name: "log", typ: "log", config: "level: warn\nfields: [title, type]\n"},
+		{name: "log unknown field", typ: "log", config: "lvl: warn\n", wantErr: "config.lvl: unknown key"},
+		{name: "log bad level", typ: "log", config: "level: loud\n", wantErr: "loud", built: true},
+		{name: "log bad field", typ: "log", config: "fields: [colour]\n", wantErr: `unknown field "colour"`, built: true},
+
+		{name: "syslog", typ: "syslog", config: "network: udp\naddress: localhost:514\nfacility: local0\n"},
+		{name: "syslog unknown field", typ: "syslog", config: "network: udp\nadress: localhost:514\n", wantErr: "config.adress: unknown key"},
+		{name: "syslog bad facility", typ: "syslog", config: "facility: local9\n", wantErr: `unknown facility "local9"`, built: true},
+		{name: "syslog missing address", typ: "syslog", config: "network: tcp\n", wantErr: `address is required for network "tcp"`, built: true},
+
+		{name: "discord-webhook", typ: "discord-webhook", config: "webhook_url: https://discord.com/api/webhooks/123/token\n"},
+		{name: "discord-webhook unknown field", typ: "discord-webhook", config: "webhook-url: https://discord.com/api/webhooks/123/token\n", wantErr: `config.webhook-url: unknown key, did you mean "webhook_url"?`},
+		{name: "discord-webhook short url", typ: "discord-webhook", config: "webhook_url: https://discord.com/api/webhooks/123\n", wantErr: "expected a path of the form /api/webhooks/<id>/<token>", built: true},
+		{name: "discord-webhook bad id", typ: "discord-webhook", config: "webhook_url: https://discord.com/api/webhooks/abc/token\n", wantErr: "invalid webhook ID", built: true},
+		{name: "discord-webhook missing url", typ: "discord-webhook", config: "", wantErr: "webhook_url is required", built: true},
+
+		{name: "journald", typ: "journald", config: "app-name: informer\n"},
+		{name: "journald unknown field", typ: "journald", config: "socket-path: /dev/log\n", wantErr: "config.socket-path: unknown key"},
+		{name: "journald bad template", typ: "journald", config: "templates:\n  default:\n    title: '{{ .Title'\n", wantErr: "unclosed action", built: true},
+
+		{name: "unknown type", typ: "discord", config: "webhook_url: https://discord.com/api/webhooks/123/token\n", wantErr: `unknown sink type "discord"`, built: true},
+		{name: "no type", typ: "", config: "", wantErr: "type is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := entryConfig(t, tt.typ, tt.config)
+			checkErr(t, "validateSink()", validateSink(c), tt.wantErr)
+
+			if tt.wantErr != "" && !tt.built {
+				return
+			}
+			conf := &Config{DataDir: t.TempDir(), Sinks: []SinkConfig{{SinkSourceConfig: c}}}
+			sinks, err := BuildSinks(conf, nil)
+			want := ""
+			if tt.built {
+				want = tt.wantErr
+			}
+			checkErr(t, "BuildSinks()", err, want)
+			closeSinks(sinks)
+		})
+	}
+}
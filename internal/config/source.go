@@ -1,25 +1,93 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/rs/zerolog/log"
+	"github.com/rtrox/informer/internal/logging"
 	"github.com/rtrox/informer/internal/source"
 	_ "github.com/rtrox/informer/internal/source/sources"
 )
 
-func UpdateSourceManagerConfig(manager *source.SourceManager, conf []SinkSourceConfig) {
-	sources := make(map[string]source.Source)
-	for _, c := range conf {
-		sources[c.Name] = source.MakeSource(c.Type, c.Config)
-		log.Info().Str("name", c.Name).Str("type", c.Type).Msg("Registered source")
+// UpdateSourceManagerConfig builds the configured sources, and hands them
+// to manager. If any source can't be built, manager is left unchanged.
+func UpdateSourceManagerConfig(manager *source.SourceManager, conf *Config) error {
+	sources, err := BuildSources(conf)
+	if err != nil {
+		return err
 	}
 	manager.UpdateSources(sources)
+	return nil
 }
 
-func ValidateSourceConfigs(conf []SinkSourceConfig) error {
-	for _, c := range conf {
-		if err := source.ValidateConfig(c.Type, c.Config); err != nil {
-			return err
+// BuildSources builds the configured sources, by name.
+func BuildSources(conf *Config) (map[string]source.Entry, error) {
+	sources := make(map[string]source.Entry)
+	var errs []error
+	for _, c := range conf.Sources {
+		if _, ok := sources[c.Name]; ok {
+			errs = append(errs, fmt.Errorf("source %s: duplicate name", c.Name))
+			continue
+		}
+		entry, err := makeSourceEntry(c, conf)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", c.Name, err))
+			continue
 		}
+		sources[c.Name] = entry
 	}
-	return nil
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for name, entry := range sources {
+		log.Info().Str("name", name).Str("type", entry.Type).Msg("Registered source")
+	}
+	return sources, nil
+}
+
+// makeSourceEntry builds a source, along with its capture, if configured.
+func makeSourceEntry(c SourceConfig, conf *Config) (source.Entry, error) {
+	node, err := ExpandNode(c.Config)
+	if err != nil {
+		return source.Entry{}, err
+	}
+	s, err := source.MakeSource(c.Type, node, source.Opts{Log: logging.For(logging.Sources)})
+	if err != nil {
+		return source.Entry{}, err
+	}
+	entry := source.Entry{Type: c.Type, Source: s}
+	if c.Capture != nil {
+		opts, err := c.Capture.Build(c.Name, conf.DataDir)
+		if err != nil {
+			return source.Entry{}, err
+		}
+		if entry.Capture, err = source.NewCapture(opts); err != nil {
+			return source.Entry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// BuildSource builds the named source alone, with opts, and without its
+// capture, e.g. to replay a webhook from the command line.
+func BuildSource(conf *Config, name string, opts source.Opts) (source.Entry, error) {
+	for _, c := range conf.Sources {
+		if c.Name != name {
+			continue
+		}
+		if err := validateSource(c.SinkSourceConfig); err != nil {
+			return source.Entry{}, err
+		}
+		node, err := ExpandNode(c.Config)
+		if err != nil {
+			return source.Entry{}, fmt.Errorf("source %s: %w", name, err)
+		}
+		s, err := source.MakeSource(c.Type, node, opts)
+		if err != nil {
+			return source.Entry{}, fmt.Errorf("source %s: %w", name, err)
+		}
+		return source.Entry{Type: c.Type, Source: s}, nil
+	}
+	return source.Entry{}, fmt.Errorf("source %s: %w", name, ErrNotFound)
 }
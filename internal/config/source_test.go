@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestSourceConfig(t *testing.T) {
+	tests := []entryTest{
+		{name: "radarr", typ: "radarr", config: "url: http://radarr:7878\napi-key: abcdef123456\n"},
+		{name: "radarr without api", typ: "radarr", config: ""},
+		{name: "radarr unknown field", typ: "radarr", config: "url: http://radarr:7878\napi_key: abcdef123456\n", wantErr: `config.api_key: unknown key, did you mean "api-key"?`},
+		{name: "radarr bad url", typ: "radarr", config: "url: radarr:7878\napi-key: abcdef123456\n", wantErr: "expected an http or https URL", built: true},
+		{name: "radarr missing api-key", typ: "radarr", config: "url: http://radarr:7878\n", wantErr: "api-key is required with url", built: true},
+
+		{name: "sonarr", typ: "sonarr", config: "url: https://sonarr.example\napi-key: abcdef123456\n"},
+		{name: "sonarr unknown field", typ: "sonarr", config: "host: sonarr\n", wantErr: "config.host: unknown key"},
+		{name: "sonarr missing url", typ: "sonarr", config: "api-key: abcdef123456\n", wantErr: "url is required with api-key", built: true},
+
+		// generic-webhook has no settings, so no value can be bad.
+		{name: "generic-webhook", typ: "generic-webhook", config: "{}\n"},
+		{name: "generic-webhook unknown field", typ: "generic-webhook", config: "secret: abc\n", wantErr: "config.secret: unknown key"},
+
+		{name: "unknown type", typ: "lidarr", config: "url: http://lidarr:8686\n", wantErr: `unknown source type "lidarr"`, built: true},
+		{name: "no type", typ: "", config: "", wantErr: "type is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := entryConfig(t, tt.typ, tt.config)
+			checkErr(t, "validateSource()", validateSource(c), tt.wantErr)
+
+			if tt.wantErr != "" && !tt.built {
+				return
+			}
+			conf := &Config{DataDir: t.TempDir(), Sources: []SourceConfig{{SinkSourceConfig: c}}}
+			_, err := BuildSources(conf)
+			want := ""
+			if tt.built {
+				want = tt.wantErr
+			}
+			checkErr(t, "BuildSources()", err, want)
+		})
+	}
+}
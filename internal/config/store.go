@@ -0,0 +1,365 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/atomicfile"
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/redact"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+// StoreFile holds the sources and sinks edited at runtime, relative to the
+// data directory. Those saved replace the sources and sinks of the same
+// name in the config file, or are added to them, and those deleted are
+// removed. Sources and sinks set, or changed, by INFORMER_* environment
+// variables or flags take precedence, and can't be edited at runtime.
+const StoreFile = "sources-sinks.yaml"
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrReadOnly = errors.New("set by environment variables or flags, so can't be edited at runtime")
+)
+
+// storedConfig holds the sources and sinks edited at runtime: those added
+// or replaced, and the names of those deleted.
+type storedConfig struct {
+	Sources        []SourceConfig `yaml:"sources,omitempty"`
+	Sinks          []SinkConfig   `yaml:"sinks,omitempty"`
+	DeletedSources []string       `yaml:"deleted-sources,omitempty"`
+	DeletedSinks   []string       `yaml:"deleted-sinks,omitempty"`
+}
+
+// Store owns the running sources and sinks config, applying changes to the
+// managers without a restart, and persisting them to the data directory.
+type Store struct {
+	conf    *Config
+	path    string
+	sinks   *sink.SinkManager
+	sources *source.SourceManager
+	keyring *encryption.Keyring
+	mut     sync.Mutex   // protects conf.Sources, conf.Sinks and stored
+	stored  storedConfig // only what was edited at runtime
+
+	// Names of the sources and sinks set outside the store, so which must
+	// be recorded as deleted, rather than just forgotten.
+	configured map[entryKey]bool
+}
+
+// NewStore applies any sources and sinks saved by an earlier run over those
+// in conf, and applies them to the managers.
+func NewStore(conf *Config, sinks *sink.SinkManager, sources *source.SourceManager, keyring *encryption.Keyring) (*Store, error) {
+	s := &Store{
+		conf:       conf,
+		path:       filepath.Join(conf.DataDir, StoreFile),
+		sinks:      sinks,
+		sources:    sources,
+		keyring:    keyring,
+		configured: make(map[entryKey]bool),
+	}
+	for _, c := range conf.Sources {
+		s.configured[entryKey{"sources", c.Name}] = true
+	}
+	for _, c := range conf.Sinks {
+		s.configured[entryKey{"sinks", c.Name}] = true
+	}
+	stored, _, err := loadStored(conf)
+	if err != nil {
+		return nil, err
+	}
+	s.stored = stored
+	builtSources, builtSinks, err := s.build(conf)
+	if err != nil {
+		return nil, fmt.Errorf("config store: %w", err)
+	}
+	s.apply(builtSources, builtSinks)
+	return s, nil
+}
+
+// LoadStored applies the sources and sinks edited at runtime over conf's,
+// returning the path they were loaded from, or "" if there are none.
+// Those conf has from environment variables or flags are kept, with a
+// warning if a saved source or sink of the same name is ignored.
+func LoadStored(conf *Config) (string, error) {
+	_, path, err := loadStored(conf)
+	return path, err
+}
+
+func loadStored(conf *Config) (storedConfig, string, error) {
+	stored := storedConfig{}
+	path := filepath.Join(conf.DataDir, StoreFile)
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return stored, "", nil
+	case err != nil:
+		return stored, "", fmt.Errorf("config store: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &stored); err != nil {
+		return stored, "", fmt.Errorf("config store: %s: %w", path, err)
+	}
+	var ignoredSources, ignoredSinks []string
+	conf.Sources, ignoredSources = overlay(conf, "sources", conf.Sources, stored.Sources, stored.DeletedSources,
+		func(c SourceConfig) string { return c.Name })
+	conf.Sinks, ignoredSinks = overlay(conf, "sinks", conf.Sinks, stored.Sinks, stored.DeletedSinks,
+		func(c SinkConfig) string { return c.Name })
+	if len(ignoredSources) > 0 || len(ignoredSinks) > 0 {
+		log.Warn().
+			Str("path", path).
+			Strs("ignored_sources", ignoredSources).
+			Strs("ignored_sinks", ignoredSinks).
+			Msg("Ignoring sources and sinks saved at runtime, which are set by environment variables or flags.")
+	}
+	log.Info().
+		Str("path", path).
+		Int("saved_sources", len(stored.Sources)).
+		Int("saved_sinks", len(stored.Sinks)).
+		Int("deleted_sources", len(stored.DeletedSources)).
+		Int("deleted_sinks", len(stored.DeletedSinks)).
+		Msg("Applied sources and sinks edited at runtime.")
+	return stored, path, nil
+}
+
+// overlay replaces the items in base with the saved items of the same name,
+// or adds them, and removes those deleted, apart from any set by
+// environment variables or flags. Returns the names of the saved and
+// deleted items ignored for that reason.
+func overlay[T any](conf *Config, section string, base, saved []T, deleted []string, nameOf func(T) string) ([]T, []string) {
+	var ignored []string
+	items := make([]T, 0, len(base)+len(saved))
+	for _, item := range base {
+		if name := nameOf(item); contains(deleted, name) {
+			if !conf.outsideFile[entryKey{section, name}] {
+				continue
+			}
+			ignored = append(ignored, name)
+		}
+		items = append(items, item)
+	}
+	for _, item := range saved {
+		name := nameOf(item)
+		if conf.outsideFile[entryKey{section, name}] {
+			ignored = append(ignored, name)
+			continue
+		}
+		if i := indexOf(items, name, nameOf); i >= 0 {
+			items[i] = item
+		} else {
+			items = append(items, item)
+		}
+	}
+	return items, ignored
+}
+
+func (s *Store) Sources() []SourceConfig {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]SourceConfig{}, s.conf.Sources...)
+}
+
+func (s *Store) Sinks() []SinkConfig {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]SinkConfig{}, s.conf.Sinks...)
+}
+
+// PutSource adds the source, or replaces the source with the same name.
+// Secrets in its config left as redacted when served are kept as they were.
+func (s *Store) PutSource(c SourceConfig) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.conf.outsideFile[entryKey{"sources", c.Name}] {
+		return fmt.Errorf("source %s: %w", c.Name, ErrReadOnly)
+	}
+	sources := append([]SourceConfig{}, s.conf.Sources...)
+	i := indexOf(sources, c.Name, func(c SourceConfig) string { return c.Name })
+	if i >= 0 {
+		redact.Restore(&c.Config, &sources[i].Config)
+	}
+	if err := validateSource(c.SinkSourceConfig); err != nil {
+		return err
+	}
+	if i >= 0 {
+		sources[i] = c
+	} else {
+		sources = append(sources, c)
+	}
+	stored := s.stored
+	stored.Sources = put(stored.Sources, c, func(c SourceConfig) string { return c.Name })
+	stored.DeletedSources = remove(stored.DeletedSources, c.Name)
+	return s.commit(sources, s.conf.Sinks, stored)
+}
+
+func (s *Store) DeleteSource(name string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	i := indexOf(s.conf.Sources, name, func(c SourceConfig) string { return c.Name })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.conf.outsideFile[entryKey{"sources", name}] {
+		return fmt.Errorf("source %s: %w", name, ErrReadOnly)
+	}
+	sources := append(append([]SourceConfig{}, s.conf.Sources[:i]...), s.conf.Sources[i+1:]...)
+	stored := s.stored
+	stored.Sources = del(stored.Sources, name, func(c SourceConfig) string { return c.Name })
+	if s.configured[entryKey{"sources", name}] {
+		stored.DeletedSources = append(remove(stored.DeletedSources, name), name)
+	}
+	return s.commit(sources, s.conf.Sinks, stored)
+}
+
+// PutSink adds the sink, or replaces the sink with the same name. Secrets
+// in its config left as redacted when served are kept as they were.
+func (s *Store) PutSink(c SinkConfig) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.conf.outsideFile[entryKey{"sinks", c.Name}] {
+		return fmt.Errorf("sink %s: %w", c.Name, ErrReadOnly)
+	}
+	sinks := append([]SinkConfig{}, s.conf.Sinks...)
+	i := indexOf(sinks, c.Name, func(c SinkConfig) string { return c.Name })
+	if i >= 0 {
+		redact.Restore(&c.Config, &sinks[i].Config)
+	}
+	if err := validateSink(c.SinkSourceConfig); err != nil {
+		return err
+	}
+	if err := s.conf.validateSinkOptions(c); err != nil {
+		return fmt.Errorf("sink %s: %w", c.Name, err)
+	}
+	if i >= 0 {
+		sinks[i] = c
+	} else {
+		sinks = append(sinks, c)
+	}
+	stored := s.stored
+	stored.Sinks = put(stored.Sinks, c, func(c SinkConfig) string { return c.Name })
+	stored.DeletedSinks = remove(stored.DeletedSinks, c.Name)
+	return s.commit(s.conf.Sources, sinks, stored)
+}
+
+func (s *Store) DeleteSink(name string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	i := indexOf(s.conf.Sinks, name, func(c SinkConfig) string { return c.Name })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.conf.outsideFile[entryKey{"sinks", name}] {
+		return fmt.Errorf("sink %s: %w", name, ErrReadOnly)
+	}
+	sinks := append(append([]SinkConfig{}, s.conf.Sinks[:i]...), s.conf.Sinks[i+1:]...)
+	stored := s.stored
+	stored.Sinks = del(stored.Sinks, name, func(c SinkConfig) string { return c.Name })
+	if s.configured[entryKey{"sinks", name}] {
+		stored.DeletedSinks = append(remove(stored.DeletedSinks, name), name)
+	}
+	return s.commit(s.conf.Sources, sinks, stored)
+}
+
+// commit builds the new sources and sinks, and only if they all build,
+// persists what was edited at runtime and applies the new config. Callers
+// must hold mut.
+func (s *Store) commit(sources []SourceConfig, sinks []SinkConfig, stored storedConfig) error {
+	next := *s.conf
+	next.Sources = sources
+	next.Sinks = sinks
+	builtSources, builtSinks, err := s.build(&next)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(stored)
+	if err == nil {
+		err = atomicfile.Write(s.path, b)
+	}
+	if err != nil {
+		closeSinks(builtSinks)
+		return fmt.Errorf("config store: %w", err)
+	}
+	s.conf.Sources = sources
+	s.conf.Sinks = sinks
+	s.stored = stored
+	s.apply(builtSources, builtSinks)
+	return nil
+}
+
+func (s *Store) build(conf *Config) (map[string]source.Entry, map[string]sink.Entry, error) {
+	sources, err := BuildSources(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	sinks, err := BuildSinks(conf, s.keyring)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sources, sinks, nil
+}
+
+func (s *Store) apply(sources map[string]source.Entry, sinks map[string]sink.Entry) {
+	if levels, err := s.conf.BuildLogLevels(); err == nil {
+		_ = logging.SetLevels(levels)
+	}
+	s.sources.UpdateSources(sources)
+	s.sinks.UpdateSinks(sinks)
+}
+
+func validateSource(c SinkSourceConfig) error {
+	return checkEntry(sourceRegistry, c)
+}
+
+func validateSink(c SinkSourceConfig) error {
+	return checkEntry(sinkRegistry, c)
+}
+
+func indexOf[T any](items []T, name string, nameOf func(T) string) int {
+	for i, item := range items {
+		if nameOf(item) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// put returns a copy of items with item added, or replacing the item with
+// the same name.
+func put[T any](items []T, item T, nameOf func(T) string) []T {
+	items = append([]T{}, items...)
+	if i := indexOf(items, nameOf(item), nameOf); i >= 0 {
+		items[i] = item
+		return items
+	}
+	return append(items, item)
+}
+
+// del returns a copy of items without the named item.
+func del[T any](items []T, name string, nameOf func(T) string) []T {
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if nameOf(item) != name {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func remove(names []string, name string) []string {
+	return del(names, name, func(n string) string { return n })
+}
+
+func contains(names []string, name string) bool {
+	return indexOf(names, name, func(n string) string { return n }) >= 0
+}
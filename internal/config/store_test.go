@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+const storeTestConfig = `
+sinks:
+  - name: file-log
+    type: log
+    config:
+      template: "${STORE_TEST_TEMPLATE}"
+  - name: edited
+    type: log
+    config:
+      level: info
+`
+
+// loadStoreTestConfig loads storeTestConfig, with a third sink set by
+// environment variables, writing its data to dir.
+func loadStoreTestConfig(t *testing.T, dir string) *Config {
+	t.Helper()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(storeTestConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STORE_TEST_TEMPLATE", "secret {{ .Title }}")
+	t.Setenv("INFORMER_DATA_DIR", dir)
+	t.Setenv("INFORMER_SINKS_2_NAME", "env-log")
+	t.Setenv("INFORMER_SINKS_2_TYPE", "log")
+	conf, err := LoadConfig(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func sinkNames(sinks []SinkConfig) string {
+	names := []string{}
+	for _, c := range sinks {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStoreSavesOnlyEdits(t *testing.T) {
+	dir := t.TempDir()
+	conf := loadStoreTestConfig(t, dir)
+	s, err := NewStore(conf, sink.NewSinkManager(sink.SinkManagerOpts{}), source.NewSourceManager(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := SinkConfig{SinkSourceConfig: entryConfig(t, "log", "level: warn\n")}
+	edited.Name = "edited"
+	if err := s.PutSink(edited); err != nil {
+		t.Fatalf("PutSink(edited) = %v", err)
+	}
+	if err := s.DeleteSink("file-log"); err != nil {
+		t.Fatalf("DeleteSink(file-log) = %v", err)
+	}
+	envLog := SinkConfig{SinkSourceConfig: entryConfig(t, "log", "level: warn\n")}
+	envLog.Name = "env-log"
+	if err := s.PutSink(envLog); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PutSink(env-log) = %v, want ErrReadOnly", err)
+	}
+	if err := s.DeleteSink("env-log"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("DeleteSink(env-log) = %v, want ErrReadOnly", err)
+	}
+	if got := sinkNames(s.Sinks()); got != "edited,env-log" {
+		t.Errorf("Sinks() = %s, want edited,env-log", got)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, StoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := storedConfig{}
+	if err := yaml.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if got := sinkNames(stored.Sinks); got != "edited" {
+		t.Errorf("saved sinks = %s, want only edited", got)
+	}
+	if got := strings.Join(stored.DeletedSinks, ","); got != "file-log" {
+		t.Errorf("deleted sinks = %s, want file-log", got)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("saved the environment's values:\n%s", b)
+	}
+
+	// A restart applies the edits over the config file and environment.
+	conf = loadStoreTestConfig(t, dir)
+	if _, err := LoadStored(conf); err != nil {
+		t.Fatal(err)
+	}
+	if got := sinkNames(conf.Sinks); got != "edited,env-log" {
+		t.Errorf("reloaded sinks = %s, want edited,env-log", got)
+	}
+	if level := conf.Sinks[0].Config.Content[1].Value; level != "warn" {
+		t.Errorf("reloaded edited level = %s, want the saved warn", level)
+	}
+}
+
+func TestLoadStoredKeepsEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	conf := loadStoreTestConfig(t, dir)
+	// As saved before edits were saved alone, with every sink.
+	stored := "sinks:\n" +
+		"  - {name: file-log, type: log, config: {level: warn}}\n" +
+		"  - {name: env-log, type: log, config: {level: warn}}\n" +
+		"deleted-sinks: [env-log]\n"
+	if err := os.WriteFile(filepath.Join(dir, StoreFile), []byte(stored), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStored(conf); err != nil {
+		t.Fatal(err)
+	}
+	if got := sinkNames(conf.Sinks); got != "file-log,edited,env-log" {
+		t.Fatalf("Sinks = %s, want file-log,edited,env-log", got)
+	}
+	if got := len(conf.Sinks[2].Config.Content); got != 0 {
+		t.Errorf("env-log config has %d nodes, want the environment's empty config", got)
+	}
+	if level := conf.Sinks[0].Config.Content[1].Value; level != "warn" {
+		t.Errorf("file-log level = %s, want the saved warn", level)
+	}
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/redact"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+// Problem is one error in a config, at its position in the config file if
+// known. Values from the environment or flags have no position.
+type Problem struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Path    string `json:"path,omitempty"` // e.g. sinks[0].config.webhook_url
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.File != "" && p.Line > 0 {
+		fmt.Fprintf(&b, "%s:%d:%d: ", p.File, p.Line, p.Column)
+	} else if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", p.Line, p.Column)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// ValidationError reports every problem found in a config, rather than
+// just the first.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// problems collects Problems, positioned by the YAML nodes they're found at.
+type problems struct {
+	file string
+	list []Problem
+
+	offline  bool                // Expand source and sink configs as LoadConfigOffline does.
+	warnings []Problem           // What couldn't be checked offline.
+	unread   map[*yaml.Node]bool // Values left unread offline.
+}
+
+// add records a problem at n, which may be nil if the position is unknown.
+// Problems with values left unread offline are left to their warning.
+func (p *problems) add(n *yaml.Node, path string, format string, args ...interface{}) {
+	if p.unread[n] {
+		return
+	}
+	p.list = append(p.list, p.problem(n, path, format, args...))
+}
+
+// warn records something which couldn't be checked at n.
+func (p *problems) warn(n *yaml.Node, path string, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, p.problem(n, path, format, args...))
+}
+
+// unresolved warns of a value left unread offline, at path if known.
+func (p *problems) unresolved(e *expandError, path string) {
+	if p.unread == nil {
+		p.unread = make(map[*yaml.Node]bool)
+	}
+	p.unread[e.node] = true
+	p.warn(e.node, path, "%s", e.err)
+}
+
+func (p *problems) problem(n *yaml.Node, path string, format string, args ...interface{}) Problem {
+	// Messages may quote values, which may be secrets.
+	problem := Problem{Path: path, Message: redact.String(fmt.Sprintf(format, args...))}
+	if n != nil && n.Line > 0 {
+		problem.File, problem.Line, problem.Column = p.file, n.Line, n.Column
+	}
+	return problem
+}
+
+func (p *problems) err() error {
+	if len(p.list) == 0 {
+		return nil
+	}
+	sort.SliceStable(p.list, func(i, j int) bool {
+		// Keep problems without a position, e.g. from the environment, last.
+		li, lj := p.list[i].Line, p.list[j].Line
+		return li != 0 && (lj == 0 || li < lj)
+	})
+	return &ValidationError{Problems: p.list}
+}
+
+// nodeAt returns the node at path within n, following mapping keys (string)
+// and sequence indexes (int), or the deepest node found along the way, so
+// that problems with missing keys are positioned at their parent.
+func nodeAt(n *yaml.Node, path ...interface{}) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	for _, step := range path {
+		var next *yaml.Node
+		switch step := step.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == step {
+						next = n.Content[i+1]
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && step < len(n.Content) {
+				next = n.Content[step]
+			}
+		}
+		if next == nil {
+			return n
+		}
+		n = next
+	}
+	return n
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// structFields returns the YAML keys of t's fields, including those of
+// inlined structs, and their types.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, inline := yamlKey(f)
+		switch {
+		case inline:
+			for k, v := range structFields(f.Type) {
+				fields[k] = v
+			}
+		case key != "":
+			fields[key] = f.Type
+		}
+	}
+	return fields
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields reports keys in n which t has no field for, e.g. typos.
+func checkKnownFields(p *problems, n *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n == nil || t == yamlNodeType || t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := structFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			ft, ok := fields[k.Value]
+			if !ok {
+				p.add(k, joinPath(path, k.Value), "unknown key%s", suggestKey(k.Value, fields))
+				continue
+			}
+			checkKnownFields(p, v, ft, joinPath(path, k.Value))
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			checkKnownFields(p, n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			checkKnownFields(p, item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+// suggestKey suggests a known key differing from key only in case or
+// punctuation, e.g. webhook_url for webhook-url.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	for k := range fields {
+		if envKey(k) == envKey(key) {
+			return fmt.Sprintf(", did you mean %q?", k)
+		}
+	}
+	return ""
+}
+
+// registry abstracts over the source and sink registries.
+type registry struct {
+	kind         string
+	isRegistered func(string) bool
+	types        func() []string
+	sample       func(string) interface{}
+	validate     func(string, yaml.Node) error
+}
+
+var (
+	sourceRegistry = registry{"source", source.IsRegistered, source.Types, source.ConfigSample, source.ValidateConfig}
+	sinkRegistry   = registry{"sink", sink.IsRegistered, sink.Types, sink.ConfigSample, sink.ValidateConfig}
+)
+
+// checkEntry checks a source or sink's name, type and config. item is its
+// node in the config file, if any.
+func (r registry) checkEntry(p *problems, c SinkSourceConfig, item *yaml.Node, path string) {
+	if c.Name == "" {
+		p.add(nodeAt(item, "name"), path, "name is required")
+	}
+	switch {
+	case c.Type == "":
+		p.add(nodeAt(item, "type"), joinPath(path, "type"), "type is required")
+		return
+	case !r.isRegistered(c.Type):
+		p.add(nodeAt(item, "type"), joinPath(path, "type"), "unknown %s type %q, expected one of %s", r.kind, c.Type, strings.Join(r.types(), ", "))
+		return
+	}
+	at := &c.Config
+	if c.Config.Kind == 0 {
+		at = nodeAt(item, "config")
+	}
+	x := &expansion{offline: p.offline}
+	node, err := x.copy(c.Config)
+	if err != nil {
+		p.add(at, joinPath(path, "config"), "%s", err)
+		return
+	}
+	for _, u := range x.unresolved {
+		p.unresolved(u, joinPath(path, "config"))
+	}
+	if sample := r.sample(c.Type); sample != nil {
+		before := len(p.list)
+		checkKnownFields(p, &node, reflect.TypeOf(sample), joinPath(path, "config"))
+		if len(p.list) > before {
+			return
+		}
+	}
+	if len(x.unresolved) > 0 {
+		p.warn(at, joinPath(path, "config"), "not checked by the %s %s type, as it has values left unread offline", c.Type, r.kind)
+		return
+	}
+	if err := r.validate(c.Type, node); err != nil {
+		p.add(at, joinPath(path, "config"), "%s", err)
+	}
+}
+
+// checkNames reports sources or sinks sharing a name, which would replace
+// one another.
+func (r registry) checkNames(p *problems, names []string, items *yaml.Node, section string) {
+	first := make(map[string]int)
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if j, ok := first[name]; ok {
+			p.add(nodeAt(items, i, "name"), fmt.Sprintf("%s[%d].name", section, i), "duplicate %s name %q, also used by %s[%d]", r.kind, name, section, j)
+			continue
+		}
+		first[name] = i
+	}
+}
+
+// checkEntry validates a single source or sink, e.g. from the admin API.
+func checkEntry(r registry, c SinkSourceConfig) error {
+	p := &problems{}
+	r.checkEntry(p, c, nil, "")
+	if err := p.err(); err != nil {
+		return fmt.Errorf("%s %s: %w", r.kind, c.Name, err)
+	}
+	return nil
+}
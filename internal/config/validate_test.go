@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateOffline(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "webhook")
+	if err := os.WriteFile(secret, []byte("https://discord.com/api/webhooks/1/token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INFORMER_TEST_PORT", "9000")
+
+	tests := []struct {
+		name         string
+		config       string
+		wantProblems []string // substrings, in order
+		wantWarnings []string
+	}{
+		{
+			name:   "defaults used",
+			config: "port: ${INFORMER_TEST_PORT:-8081}\n",
+		},
+		{
+			name:         "environment left unread",
+			config:       "port: ${INFORMER_TEST_PORT}\n",
+			wantWarnings: []string{"1:7: environment variable INFORMER_TEST_PORT isn't read offline"},
+		},
+		{
+			name:   "file left unread",
+			config: "sinks:\n  - name: discord\n    type: discord-webhook\n    config:\n      webhook_url_file: " + secret + "\n",
+			wantWarnings: []string{
+				"sinks[0].config: webhook_url_file is read from a file",
+				"sinks[0].config: not checked by the discord-webhook sink type",
+			},
+		},
+		{
+			name:         "unknown keys still found",
+			config:       "sinks:\n  - name: discord\n    type: discord-webhook\n    config:\n      webhook_url: ${DISCORD_URL}\n      colour: red\n",
+			wantProblems: []string{"sinks[0].config.colour: unknown key"},
+			wantWarnings: []string{"sinks[0].config: environment variable DISCORD_URL isn't read offline"},
+		},
+		{
+			name:         "other problems still found",
+			config:       "port: ${PORT}\nlog-format: xml\n",
+			wantProblems: []string{"log-format"},
+			wantWarnings: []string{"environment variable PORT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := LoadConfigOffline(path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var problems []Problem
+			if err := conf.Validate(); err != nil {
+				var invalid *ValidationError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("Validate() = %v, want a *ValidationError", err)
+				}
+				problems = invalid.Problems
+			}
+			checkProblems(t, "problems", problems, tt.wantProblems)
+			checkProblems(t, "warnings", conf.Warnings(), tt.wantWarnings)
+		})
+	}
+}
+
+func TestValidateOnline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: ${INFORMER_TEST_UNSET}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path, nil); err == nil || !strings.Contains(err.Error(), "INFORMER_TEST_UNSET is not set") {
+		t.Errorf("LoadConfig() = %v, want an unset variable error", err)
+	}
+}
+
+func checkProblems(t *testing.T, kind string, got []Problem, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %d", kind, got, len(want))
+		return
+	}
+	for i, w := range want {
+		if !strings.Contains(got[i].String(), w) {
+			t.Errorf("%s[%d] = %q, want it to contain %q", kind, i, got[i].String(), w)
+		}
+	}
+}
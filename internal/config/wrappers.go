@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/rtrox/informer/internal/auth"
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/history"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/schedule"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/source"
+)
+
+// FilterConfig selects events by type and source. Empty lists match
+// everything.
+type FilterConfig struct {
+	EventTypes []string `yaml:"event-types"`
+	Sources    []string `yaml:"sources"`
+}
+
+func (f FilterConfig) Build() (sink.Filter, error) {
+	filter := sink.Filter{Sources: f.Sources}
+	for _, name := range f.EventTypes {
+		t, err := event.ParseEventType(name)
+		if err != nil {
+			return sink.Filter{}, err
+		}
+		filter.EventTypes = append(filter.EventTypes, t)
+	}
+	return filter, nil
+}
+
+// DigestConfig batches a sink's events into periodic summaries.
+type DigestConfig struct {
+	Schedule string       `yaml:"schedule"` // Cron expression, descriptor (@daily) or interval (@every 1h).
+	Timezone string       `yaml:"timezone"` // IANA zone the schedule is evaluated in. Defaults to local time.
+	Title    string       `yaml:"title"`
+	Filter   FilterConfig `yaml:"filter"` // Events to summarize. Others are delivered immediately.
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+func (d DigestConfig) Build(sinkName string, dataDir string) (sink.DigestOpts, error) {
+	loc, err := loadLocation(d.Timezone)
+	if err != nil {
+		return sink.DigestOpts{}, fmt.Errorf("digest: %w", err)
+	}
+	sched, err := schedule.Parse(d.Schedule, loc)
+	if err != nil {
+		return sink.DigestOpts{}, fmt.Errorf("digest: %w", err)
+	}
+	filter, err := d.Filter.Build()
+	if err != nil {
+		return sink.DigestOpts{}, fmt.Errorf("digest: %w", err)
+	}
+	return sink.DigestOpts{
+		Schedule: sched,
+		Filter:   filter,
+		Title:    d.Title,
+		Path:     filepath.Join(dataDir, "digest", sinkName+".json"),
+	}, nil
+}
+
+// CaptureConfig writes each raw request a source receives, with headers
+// other than auth, and the resulting event or error, to a directory.
+type CaptureConfig struct {
+	Dir      string `yaml:"dir"`       // Defaults to captures/<source name> in the data directory.
+	MaxFiles int    `yaml:"max-files"` // The oldest captures are removed beyond this many. Defaults to 100.
+}
+
+func (c CaptureConfig) Build(sourceName string, dataDir string) (source.CaptureOpts, error) {
+	opts := source.CaptureOpts{Dir: c.Dir, MaxFiles: c.MaxFiles}
+	if opts.Dir == "" {
+		opts.Dir = filepath.Join(dataDir, "captures", sourceName)
+	}
+	if opts.MaxFiles == 0 {
+		opts.MaxFiles = 100
+	}
+	if opts.MaxFiles < 0 {
+		return source.CaptureOpts{}, fmt.Errorf("capture: max-files must not be negative")
+	}
+	return opts, nil
+}
+
+// CoalesceConfig combines bursts of similar events into one, e.g. each
+// episode of an imported season.
+type CoalesceConfig struct {
+	Window  time.Duration `yaml:"window"`   // Deliver once no similar event has arrived for this long.
+	MaxWait time.Duration `yaml:"max-wait"` // Deliver no later than this after the first event. Defaults to 4x window.
+	Key     []string      `yaml:"key"`      // Event fields which make events similar, as for dedup.fingerprint.
+	Filter  FilterConfig  `yaml:"filter"`   // Events eligible for coalescing. Others are delivered immediately.
+}
+
+func (c CoalesceConfig) Build() (sink.CoalesceOpts, error) {
+	if err := event.ValidateFingerprintFields(c.Key); err != nil {
+		return sink.CoalesceOpts{}, fmt.Errorf("coalesce: %w", err)
+	}
+	filter, err := c.Filter.Build()
+	if err != nil {
+		return sink.CoalesceOpts{}, fmt.Errorf("coalesce: %w", err)
+	}
+	if c.Window <= 0 {
+		return sink.CoalesceOpts{}, fmt.Errorf("coalesce: window must be positive")
+	}
+	return sink.CoalesceOpts{
+		Window:  c.Window,
+		MaxWait: c.MaxWait,
+		Fields:  c.Key,
+		Filter:  filter,
+	}, nil
+}
+
+// RateLimitConfig limits how quickly a sink is sent events. Events over the
+// limit wait in the sink's queue.
+type RateLimitConfig struct {
+	Events int           `yaml:"events"` // Events allowed per period.
+	Per    time.Duration `yaml:"per"`    // Defaults to 1m.
+	Burst  int           `yaml:"burst"`  // Events which may be sent back to back. Defaults to 1.
+}
+
+func (r RateLimitConfig) Build() sink.RateLimitOpts {
+	return sink.RateLimitOpts{
+		Events: r.Events,
+		Per:    r.Per,
+		Burst:  r.Burst,
+	}
+}
+
+type WindowConfig struct {
+	Days  []string `yaml:"days"`  // Weekdays, e.g. mon, tue. Defaults to every day.
+	Start string   `yaml:"start"` // HH:MM
+	End   string   `yaml:"end"`   // HH:MM, may be before start to run past midnight.
+}
+
+// ScheduleConfig restricts a sink to delivering events during windows,
+// e.g. outside of quiet hours.
+type ScheduleConfig struct {
+	Timezone string         `yaml:"timezone"` // IANA zone the windows are evaluated in. Defaults to local time.
+	Windows  []WindowConfig `yaml:"windows"`
+	Action   string         `yaml:"action"` // What to do outside the windows: drop, hold (default) or digest.
+	Always   []string       `yaml:"always"` // Event types delivered regardless of the windows, e.g. HealthIssue.
+}
+
+// Build returns the options for the sink's schedule. digest is the sink's
+// digest, if configured, which the digest action adds events to.
+func (s ScheduleConfig) Build(sinkName string, dataDir string, digest *sink.Digest) (sink.WindowOpts, error) {
+	loc, err := loadLocation(s.Timezone)
+	if err != nil {
+		return sink.WindowOpts{}, fmt.Errorf("schedule: %w", err)
+	}
+	opts := sink.WindowOpts{
+		Windows: schedule.Windows{Location: loc},
+		Action:  sink.OutOfWindowAction(s.Action),
+		Digest:  digest,
+		Path:    filepath.Join(dataDir, "held", sinkName+".json"),
+	}
+	if opts.Action == "" {
+		opts.Action = sink.OutOfWindowHold
+	}
+	switch opts.Action {
+	case sink.OutOfWindowDrop, sink.OutOfWindowHold, sink.OutOfWindowDigest:
+	default:
+		return sink.WindowOpts{}, fmt.Errorf("schedule: unknown action %q", s.Action)
+	}
+	for _, wc := range s.Windows {
+		w, err := schedule.ParseWindow(wc.Start, wc.End, wc.Days)
+		if err != nil {
+			return sink.WindowOpts{}, fmt.Errorf("schedule: %w", err)
+		}
+		opts.Windows.Windows = append(opts.Windows.Windows, w)
+	}
+	for _, name := range s.Always {
+		t, err := event.ParseEventType(name)
+		if err != nil {
+			return sink.WindowOpts{}, fmt.Errorf("schedule: %w", err)
+		}
+		opts.Always = append(opts.Always, t)
+	}
+	return opts, nil
+}
+
+func (h HistoryConfig) Build(dataDir string, keyring *encryption.Keyring) history.Opts {
+	path := h.Path
+	if path == "" {
+		path = filepath.Join(dataDir, "history.db")
+	}
+	return history.Opts{
+		Path:      path,
+		MaxAge:    h.MaxAge,
+		MaxEvents: h.MaxEvents,
+		Keyring:   keyring,
+	}
+}
+
+func (a AuthConfig) Build(admin AdminConfig, dataDir string) (auth.Opts, error) {
+	opts := auth.Opts{
+		Path:       filepath.Join(dataDir, "users.json"),
+		SessionTTL: a.SessionTTL,
+		Proxy:      auth.ProxyOpts{Header: a.Proxy.Header},
+	}
+	for _, u := range a.Users {
+		if u.Username == "" || u.PasswordHash == "" {
+			return auth.Opts{}, fmt.Errorf("auth: users require a username and password-hash")
+		}
+		opts.Users = append(opts.Users, auth.User{Username: u.Username, PasswordHash: u.PasswordHash})
+	}
+	if admin.Token != "" {
+		opts.StaticTokens = append(opts.StaticTokens, admin.Token)
+	}
+	if a.Proxy.Header != "" && len(a.Proxy.TrustedProxies) == 0 {
+		return auth.Opts{}, fmt.Errorf("auth: proxy: trusted-proxies is required with header")
+	}
+	trusted, err := auth.ParseTrustedProxies(a.Proxy.TrustedProxies)
+	if err != nil {
+		return auth.Opts{}, fmt.Errorf("auth: proxy: %w", err)
+	}
+	opts.Proxy.TrustedProxies = trusted
+	return opts, nil
+}
+
+// BuildLogLevels returns the level of each logging component: the default
+// from log-level, those in log-levels, and each sink's own.
+func (c *Config) BuildLogLevels() (map[string]zerolog.Level, error) {
+	levels := make(map[string]zerolog.Level)
+	def, err := logging.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("log-level: %w", err)
+	}
+	levels[logging.Default] = def
+	for component, level := range c.LogLevels {
+		switch component {
+		case logging.Broker, logging.Sources, logging.Sinks:
+		default:
+			return nil, fmt.Errorf("log-levels: unknown component %q, expected %s, %s or %s", component, logging.Broker, logging.Sources, logging.Sinks)
+		}
+		if levels[component], err = logging.ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("log-levels: %s: %w", component, err)
+		}
+	}
+	for _, s := range c.Sinks {
+		if s.LogLevel == "" {
+			continue
+		}
+		if levels[logging.Sink(s.Name)], err = logging.ParseLevel(s.LogLevel); err != nil {
+			return nil, fmt.Errorf("sink %s: log-level: %w", s.Name, err)
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,188 @@
+// Package encryption seals state Informer persists about monitored apps
+// (event history, digest buffers, held events), using envelope encryption:
+// each record is encrypted with its own random data key, which is in turn
+// encrypted with the configured key.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KeySize = 32 // AES-256
+
+	keyIDSize      = 8
+	nonceSize      = 12
+	wrappedKeySize = nonceSize + KeySize + 16 // nonce, data key, GCM tag
+)
+
+// magic prefixes sealed records. Plaintext records are JSON, which can't
+// start with a NUL byte.
+var magic = []byte{0x00, 'I', 'E', 0x01}
+
+var (
+	ErrNoKey      = errors.New("data is encrypted, but no encryption key is configured")
+	ErrUnknownKey = errors.New("data is encrypted with a key which is not configured")
+)
+
+// Keyring seals records with its current key, and opens records sealed with
+// the current key or any previous key, so that keys can be rotated. A nil
+// Keyring leaves records in plaintext.
+type Keyring struct {
+	current string // ID of the key records are sealed with
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring returns a Keyring sealing records with current. Records sealed
+// with any of previous can still be opened.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for i, key := range append([][]byte{current}, previous...) {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.current = id
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKey decodes a key from its base64 or hex text form, e.g. the output
+// of `openssl rand -base64 32`.
+func ParseKey(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("key is empty")
+	}
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("key must be %d bytes, base64 or hex encoded", KeySize)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return string(sum[:keyIDSize])
+}
+
+// IsSealed reports whether data was produced by Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plaintext under a new data key. A nil Keyring returns
+// plaintext unchanged.
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, magic...), k.current...)
+	out := make([]byte, 0, len(header)+wrappedKeySize+nonceSize+len(plaintext)+data.Overhead())
+	out = append(out, header...)
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	out = k.keys[k.current].Seal(out, nonce, dataKey, header)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return data.Seal(out, nonce, plaintext, header), nil
+}
+
+// Open decrypts data produced by Seal. Data which isn't sealed is returned
+// unchanged, so that stores written before encryption was enabled remain
+// readable.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	headerSize := len(magic) + keyIDSize
+	if len(data) < headerSize+wrappedKeySize+nonceSize {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	header := data[:headerSize]
+	kek, ok := k.keys[string(header[len(magic):])]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	wrapped := data[headerSize : headerSize+wrappedKeySize]
+	dataKey, err := kek.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	body := data[headerSize+wrappedKeySize:]
+	plaintext, err := aead.Open(nil, body[:nonceSize], body[nonceSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// NeedsRekey reports whether data should be sealed again: it is plaintext
+// while a key is configured, or it was sealed with a previous key.
+func (k *Keyring) NeedsRekey(data []byte) bool {
+	if k == nil {
+		return false
+	}
+	if !IsSealed(data) {
+		return true
+	}
+	id := data[len(magic):]
+	if len(id) < keyIDSize {
+		return false
+	}
+	return string(id[:keyIDSize]) != k.current
+}
@@ -0,0 +1,190 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newKeyring(t *testing.T, current []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func seal(t *testing.T, k *Keyring, plaintext []byte) []byte {
+	t.Helper()
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func TestSealOpen(t *testing.T) {
+	k := newKeyring(t, newKey(t))
+	plaintext := []byte(`{"title": "Movie Grabbed"}`)
+
+	sealed := seal(t, k, plaintext)
+	if !IsSealed(sealed) {
+		t.Fatal("IsSealed(sealed) = false")
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealed data contains the plaintext")
+	}
+	if again := seal(t, k, plaintext); bytes.Equal(again, sealed) {
+		t.Error("sealing twice gave the same ciphertext, want a new data key and nonce each time")
+	}
+	opened, err := k.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, want %q", opened, plaintext)
+	}
+	if k.NeedsRekey(sealed) {
+		t.Error("NeedsRekey(sealed with the current key) = true")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, currentKey := newKey(t), newKey(t)
+	plaintext := []byte(`{"title": "Movie Grabbed"}`)
+	sealed := seal(t, newKeyring(t, oldKey), plaintext)
+
+	rotated := newKeyring(t, currentKey, oldKey)
+	opened, err := rotated.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open(sealed with the previous key) = %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, want %q", opened, plaintext)
+	}
+	if !rotated.NeedsRekey(sealed) {
+		t.Error("NeedsRekey(sealed with the previous key) = false")
+	}
+	if rotated.NeedsRekey(seal(t, rotated, plaintext)) {
+		t.Error("NeedsRekey(sealed with the new key) = true")
+	}
+
+	// Once the previous key is removed, its records can't be opened.
+	if _, err := newKeyring(t, currentKey).Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open without the previous key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	key := newKey(t)
+	k := newKeyring(t, key)
+	sealed := seal(t, k, []byte(`{"title": "Movie Grabbed"}`))
+	headerSize := len(magic) + keyIDSize
+
+	// A keyring with a different key, which claims the same key ID.
+	impostor := newKeyring(t, newKey(t))
+	impostor.keys = map[string]cipher.AEAD{k.current: impostor.keys[impostor.current]}
+	impostor.current = k.current
+
+	tests := []struct {
+		name string
+		k    *Keyring
+		data func([]byte) []byte
+	}{
+		{"tampered data", k, func(b []byte) []byte { b[len(b)-1] ^= 1; return b }},
+		{"tampered data key", k, func(b []byte) []byte { b[headerSize+nonceSize] ^= 1; return b }},
+		{"tampered key ID", k, func(b []byte) []byte { b[len(magic)] ^= 1; return b }},
+		{"truncated", k, func(b []byte) []byte { return b[:headerSize+wrappedKeySize] }},
+		{"wrong key", newKeyring(t, newKey(t)), func(b []byte) []byte { return b }},
+		{"wrong key with the same ID", impostor, func(b []byte) []byte { return b }},
+		{"no keyring", nil, func(b []byte) []byte { return b }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(append([]byte{}, sealed...))
+			if opened, err := tt.k.Open(data); err == nil {
+				t.Errorf("Open = %q, want an error", opened)
+			}
+		})
+	}
+}
+
+func TestPlaintextPassthrough(t *testing.T) {
+	plaintext := []byte(`{"title": "Movie Grabbed"}`)
+
+	var none *Keyring
+	sealed, err := none.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sealed, plaintext) {
+		t.Errorf("nil Keyring Seal = %q, want the plaintext", sealed)
+	}
+	if none.NeedsRekey(plaintext) {
+		t.Error("nil Keyring NeedsRekey(plaintext) = true")
+	}
+
+	// Records written before encryption was enabled are still readable,
+	// and sealed on rekeying.
+	for name, k := range map[string]*Keyring{"nil": nil, "configured": newKeyring(t, newKey(t))} {
+		opened, err := k.Open(plaintext)
+		if err != nil {
+			t.Fatalf("%s Keyring Open(plaintext) = %v", name, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("%s Keyring Open(plaintext) = %q, want it unchanged", name, opened)
+		}
+	}
+	if !newKeyring(t, newKey(t)).NeedsRekey(plaintext) {
+		t.Error("NeedsRekey(plaintext) = false, with a key configured")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, KeySize)
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"base64", base64.StdEncoding.EncodeToString(key) + "\n", false},
+		{"hex", hex.EncodeToString(key), false},
+		{"empty", "  \n", true},
+		{"short", base64.StdEncoding.EncodeToString(key[:16]), true},
+		{"not encoded", "not a key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseKey = %x, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, key) {
+				t.Errorf("ParseKey = %x, want %x", got, key)
+			}
+		})
+	}
+	if _, err := NewKeyring(key[:16]); err == nil {
+		t.Error("NewKeyring(16 byte key) succeeded, want an error")
+	}
+}
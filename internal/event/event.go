@@ -1,6 +1,11 @@
 package event
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
 
 type EventType int
 
@@ -40,35 +45,54 @@ func (e EventType) String() string {
 	}[e]
 }
 
-type MetadataList []MetadataField
-
-func (m *MetadataList) Add(name string, value string) {
-	*m = append(*m, MetadataField{Name: name, Value: value, Inline: false})
+type Event struct {
+	ID              string       `json:"id"`              // Unique ID for this event, assigned when it is received. Time ordered.
+	ReceivedAt      time.Time    `json:"received_at"`     // When Informer received the event.
+	OccurredAt      *time.Time   `json:"occurred_at"`     // When the source reports the event occurred. Nil if not reported.
+	CorrelationID   string       `json:"correlation_id"`  // Ties related events together, e.g. the *arr downloadId shared by a Grab and its Download. Empty if unknown.
+	SourceInstance  string       `json:"source_instance"` // The name the source reports for itself, e.g. Sonarr's InstanceName.
+	EventType       EventType    `json:"type"`            // An enum of event types which destinations know how to react to. Should be used to choose the template
+	Title           string       `json:"title"`           // Specific to the source, a human readable name for what occurred. This will be used as the Title, Subject, etc in destinations.
+	Description     string       `json:"description"`     // A description of what occurred. This field should be limited to the text size of the smallest initial destination. This description should assume Discord's subset of markdown, and other destinations can adjust as needed.
+	ThumbnailURL    *string      `json:"thumbnail_url"`   // A thumbnail to associate with the event. Nil if no thumbnail.
+	ImageURL        *string      `json:"image_url"`       // An Image to associate with the event. Nil if no image.
+	LinkURL         *string      `json:"link_url"`        // A link to associate with the event. Nil if no link.
+	Source          string       `json:"source"`          // The source of the event. This should not be used for routing, but can be used for logging and debugging.
+	SourceEventType string       `json:"source_event"`    // The specific event type from the source. This should not be used for routing, but can be used for logging and debugging.
+	SourceIconURL   string       `json:"source_icon"`     // An icon to associate with this source.
+	Metadata        MetadataList `json:"metadata"`        // Arbitrary metadata about this event, which destinations should assume will be rendered as a key value table. Sinks should not rely on the existence of any specific key.
 }
 
-func (m *MetadataList) AddInline(name string, value string) {
-	*m = append(*m, MetadataField{Name: name, Value: value, Inline: true})
+func (e *Event) Bind(r *http.Request) error {
+	return nil
 }
 
-type MetadataField struct {
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	Inline bool   `json:"inline"`
+// Stamp assigns an ID and receipt time to the event, unless already set.
+func (e *Event) Stamp() {
+	if e.ID == "" {
+		e.ID = NewID()
+	}
+	if e.ReceivedAt.IsZero() {
+		e.ReceivedAt = time.Now()
+	}
 }
 
-type Event struct {
-	EventType       EventType    `json:"type"`          // An enum of event types which destinations know how to react to. Should be used to choose the template
-	Title           string       `json:"title"`         // Specific to the source, a human readable name for what occurred. This will be used as the Title, Subject, etc in destinations.
-	Description     string       `json:"description"`   // A description of what occurred. This field should be limited to the text size of the smallest initial destination. This description should assume Discord's subset of markdown, and other destinations can adjust as needed.
-	ThumbnailURL    *string      `json:"thumbnail_url"` // A thumbnail to associate with the event. Nil if no thumbnail.
-	ImageURL        *string      `json:"image_url"`     // An Image to associate with the event. Nil if no image.
-	LinkURL         *string      `json:"link_url"`      // A link to associate with the event. Nil if no link.
-	Source          string       `json:"source"`        // The source of the event. This should not be used for routing, but can be used for logging and debugging.
-	SourceEventType string       `json:"source_event"`  // The specific event type from the source. This should not be used for routing, but can be used for logging and debugging.
-	SourceIconURL   string       `json:"source_icon"`   // An icon to associate with this source.
-	Metadata        MetadataList `json:"metadata"`      // Arbitrary metadata about this event, which destinations should assume will be rendered as a key value table. Sinks should not rely on the existence of any specific key.
+// Time returns when the event occurred, as reported by the source, falling
+// back to when it was received.
+func (e Event) Time() time.Time {
+	if e.OccurredAt != nil {
+		return *e.OccurredAt
+	}
+	return e.ReceivedAt
 }
 
-func (e *Event) Bind(r *http.Request) error {
-	return nil
+// ParseEventType returns the EventType matching the provided name, as
+// produced by EventType.String(). Matching is case-insensitive.
+func ParseEventType(name string) (EventType, error) {
+	for t := Unknown; t <= TestEvent; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, nil
+		}
+	}
+	return Unknown, fmt.Errorf("unknown event type: %s", name)
 }
@@ -0,0 +1,58 @@
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// FingerprintMetadataPrefix selects a metadata value by key in a
+// fingerprint field list, e.g. metadata.quality.
+const FingerprintMetadataPrefix = "metadata."
+
+// DefaultFingerprintFields identify an event by what a user would see.
+var DefaultFingerprintFields = []string{"source", "source_event", "title"}
+
+var fingerprintFields = map[string]func(Event) string{
+	"type":            func(e Event) string { return e.EventType.String() },
+	"title":           func(e Event) string { return e.Title },
+	"description":     func(e Event) string { return e.Description },
+	"source":          func(e Event) string { return e.Source },
+	"source_event":    func(e Event) string { return e.SourceEventType },
+	"source_instance": func(e Event) string { return e.SourceInstance },
+	"correlation_id":  func(e Event) string { return e.CorrelationID },
+}
+
+// ValidateFingerprintFields checks that every field can be fingerprinted.
+func ValidateFingerprintFields(fields []string) error {
+	for _, f := range fields {
+		if strings.HasPrefix(f, FingerprintMetadataPrefix) && len(f) > len(FingerprintMetadataPrefix) {
+			continue
+		}
+		if _, ok := fingerprintFields[f]; !ok {
+			return fmt.Errorf("unknown fingerprint field: %s", f)
+		}
+	}
+	return nil
+}
+
+// Fingerprint returns a stable hash of the selected fields of the event,
+// named as in its JSON encoding. Events with equal fingerprints are
+// considered the same occurrence. Unknown fields are ignored.
+func Fingerprint(e Event, fields []string) string {
+	h := sha256.New()
+	for _, f := range fields {
+		var v string
+		if key := strings.TrimPrefix(f, FingerprintMetadataPrefix); key != f {
+			if m := e.Metadata.Get(key); m != nil {
+				v = fmt.Sprint(m.Raw)
+			}
+		} else if fn, ok := fingerprintFields[f]; ok {
+			v = fn(e)
+		}
+		// Length prefix each value, so that field boundaries can't collide.
+		fmt.Fprintf(h, "%s:%d:%s;", f, len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
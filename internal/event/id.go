@@ -0,0 +1,21 @@
+package event
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// NewID returns a new UUIDv7 (RFC 9562). UUIDv7 embeds a millisecond
+// timestamp, so IDs sort in the order events were received.
+func NewID() string {
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[0:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(u[6:]); err != nil {
+		panic("event: failed to read random bytes: " + err.Error())
+	}
+	u[6] = (u[6] & 0x0f) | 0x70 // version 7
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,210 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MetadataType describes the type of a MetadataField's Raw value.
+type MetadataType string
+
+const (
+	MetadataTypeString   MetadataType = "string"   // Raw is a string.
+	MetadataTypeInt      MetadataType = "int"      // Raw is an int64.
+	MetadataTypeBytes    MetadataType = "bytes"    // Raw is an int64 count of bytes.
+	MetadataTypeDuration MetadataType = "duration" // Raw is a time.Duration.
+	MetadataTypeTime     MetadataType = "time"     // Raw is a time.Time.
+	MetadataTypeURL      MetadataType = "url"      // Raw is a string containing an absolute URL.
+	MetadataTypeList     MetadataType = "list"     // Raw is a []string.
+)
+
+type MetadataList []MetadataField
+
+// MetadataField is a single piece of metadata. Value is always a human
+// friendly rendering for chat sinks, while Key, Type and Raw carry the
+// machine readable value for templates, filters and structured sinks.
+type MetadataField struct {
+	Key    string       `json:"key"`   // Stable, snake_case machine key, e.g. file_size.
+	Name   string       `json:"name"`  // Display name, e.g. File Size.
+	Value  string       `json:"value"` // Display value, e.g. 1.4 GiB.
+	Type   MetadataType `json:"type"`
+	Raw    interface{}  `json:"raw"`
+	Inline bool         `json:"inline"`
+}
+
+// Add appends a string field, keyed by its display name.
+func (m *MetadataList) Add(name string, value string) {
+	m.AddString(KeyFromName(name), name, value, false)
+}
+
+// AddInline appends an inline string field, keyed by its display name.
+func (m *MetadataList) AddInline(name string, value string) {
+	m.AddString(KeyFromName(name), name, value, true)
+}
+
+// AddField appends a field as provided. Fields without a key or type are
+// keyed by their display name, and typed as strings.
+func (m *MetadataList) AddField(f MetadataField) {
+	if f.Key == "" {
+		f.Key = KeyFromName(f.Name)
+	}
+	if f.Type == "" {
+		f.Type = MetadataTypeString
+		f.Raw = f.Value
+	}
+	*m = append(*m, f)
+}
+
+func (m *MetadataList) AddString(key string, name string, value string, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: value, Type: MetadataTypeString, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddInt(key string, name string, value int64, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: fmt.Sprintf("%d", value), Type: MetadataTypeInt, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddBytes(key string, name string, value int64, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: FormatBytes(value), Type: MetadataTypeBytes, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddDuration(key string, name string, value time.Duration, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: FormatDuration(value), Type: MetadataTypeDuration, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddTime(key string, name string, value time.Time, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: value.Format("2006-01-02 15:04 MST"), Type: MetadataTypeTime, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddURL(key string, name string, value string, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: value, Type: MetadataTypeURL, Raw: value, Inline: inline})
+}
+
+func (m *MetadataList) AddList(key string, name string, value []string, inline bool) {
+	m.AddField(MetadataField{Key: key, Name: name, Value: strings.Join(value, ", "), Type: MetadataTypeList, Raw: value, Inline: inline})
+}
+
+// Get returns the first field with the provided key, or nil.
+func (m MetadataList) Get(key string) *MetadataField {
+	for i := range m {
+		if m[i].Key == key {
+			return &m[i]
+		}
+	}
+	return nil
+}
+
+// Raw returns the typed value of the first field with the provided key,
+// or nil. Intended for templates: {{ .Metadata.Raw "file_size" }}.
+func (m MetadataList) Raw(key string) interface{} {
+	if f := m.Get(key); f != nil {
+		return f.Raw
+	}
+	return nil
+}
+
+// UnmarshalJSON restores Raw to the Go type documented for the field's Type,
+// so events decoded from JSON compare like events built in process.
+func (f *MetadataField) UnmarshalJSON(b []byte) error {
+	type plain MetadataField
+	var p struct {
+		plain
+		Raw json.RawMessage `json:"raw"`
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*f = MetadataField(p.plain)
+	if f.Key == "" {
+		f.Key = KeyFromName(f.Name)
+	}
+	if f.Type == "" {
+		f.Type = MetadataTypeString
+	}
+	if len(p.Raw) == 0 || string(p.Raw) == "null" {
+		if f.Type == MetadataTypeString {
+			f.Raw = f.Value
+		}
+		return nil
+	}
+
+	var err error
+	switch f.Type {
+	case MetadataTypeInt, MetadataTypeBytes:
+		var v int64
+		err = json.Unmarshal(p.Raw, &v)
+		f.Raw = v
+	case MetadataTypeDuration:
+		var v time.Duration
+		err = json.Unmarshal(p.Raw, &v)
+		f.Raw = v
+	case MetadataTypeTime:
+		var v time.Time
+		err = json.Unmarshal(p.Raw, &v)
+		f.Raw = v
+	case MetadataTypeList:
+		var v []string
+		err = json.Unmarshal(p.Raw, &v)
+		f.Raw = v
+	default:
+		var v string
+		err = json.Unmarshal(p.Raw, &v)
+		f.Raw = v
+	}
+	if err != nil {
+		return fmt.Errorf("metadata %s: %w", f.Key, err)
+	}
+	return nil
+}
+
+// KeyFromName derives a snake_case machine key from a display name,
+// e.g. "File Size" becomes "file_size".
+func KeyFromName(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if underscore && b.Len() > 0 {
+				b.WriteRune('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+			continue
+		}
+		underscore = true
+	}
+	return b.String()
+}
+
+// FormatBytes renders a byte count using binary units, e.g. 1.4 GiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatDuration renders a duration at minute precision, e.g. 1h 32m.
+// Durations under a minute are rendered in seconds.
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+	}
+	d = d.Round(time.Minute)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+}
@@ -0,0 +1,77 @@
+package event
+
+import "time"
+
+// Sample returns a canned example of an event of type t, for trying out
+// sinks and templates without a source.
+func Sample(t EventType) Event {
+	link := "https://www.themoviedb.org/movie/603"
+	poster := "https://image.tmdb.org/t/p/w500/f89U3ADr1oiB1s9GkdPOEpXUk5H.jpg"
+	e := Event{
+		EventType:       t,
+		CorrelationID:   "sample-download",
+		SourceInstance:  "Sample",
+		Source:          "Informer",
+		SourceEventType: "Sample",
+		ThumbnailURL:    &poster,
+		LinkURL:         &link,
+	}
+	switch t {
+	case ObjectAdded:
+		e.Title = "Movie Added: The Matrix (1999)"
+		e.Description = "The Matrix was added, and is being searched for."
+	case ObjectGrabbed:
+		e.Title = "Movie Grabbed: The Matrix (1999)"
+		e.Description = "The.Matrix.1999.1080p.BluRay.x264 was sent to the download client."
+		e.Metadata.AddString("quality", "Quality", "Bluray-1080p", true)
+		e.Metadata.AddBytes("size", "Size", 10737418240, true)
+		e.Metadata.AddString("indexer", "Indexer", "Sample Indexer", true)
+	case ObjectDownloaded:
+		e.Title = "Movie Downloaded: The Matrix (1999)"
+		e.Description = "The.Matrix.1999.1080p.BluRay.x264 was imported."
+		e.Metadata.AddString("quality", "Quality", "Bluray-1080p", true)
+		e.Metadata.AddBytes("size", "Size", 10737418240, true)
+	case ObjectRenamed:
+		e.Title = "Movie Renamed: The Matrix (1999)"
+		e.Description = "The Matrix's files were renamed."
+	case ObjectUpdated:
+		e.Title = "Movie Updated: The Matrix (1999)"
+		e.Description = "The Matrix's details were refreshed."
+	case ObjectCompleted:
+		e.Title = "Movie Completed: The Matrix (1999)"
+		e.Description = "Every file for The Matrix is downloaded."
+	case ObjectFailed:
+		e.Title = "Download Failed: The Matrix (1999)"
+		e.Description = "The.Matrix.1999.1080p.BluRay.x264 failed to download: the download client reported an error."
+		e.Metadata.AddString("download_client", "Download Client", "Sample Client", true)
+	case ObjectFileDeleted:
+		e.Title = "Movie File Deleted: The Matrix (1999)"
+		e.Description = "The.Matrix.1999.1080p.BluRay.x264.mkv was deleted, for an upgrade."
+	case ObjectDeleted:
+		e.Title = "Movie Deleted: The Matrix (1999)"
+		e.Description = "The Matrix was removed from the library."
+	case Informational:
+		e.Title = "Application Update"
+		e.Description = "Version 1.2.3 is installed."
+	case HealthIssue:
+		e.Title = "Health Issue"
+		e.Description = "Indexers unavailable due to failures: Sample Indexer"
+		e.Metadata.AddString("level", "Level", "warning", true)
+	case HealthRestored:
+		e.Title = "Health Restored"
+		e.Description = "Indexers unavailable due to failures: Sample Indexer"
+	default:
+		e.Title = "Test Event"
+		e.Description = "A sample event, sent by Informer."
+	}
+	switch t {
+	case Informational, HealthIssue, HealthRestored, TestEvent, Unknown:
+		e.CorrelationID = ""
+		e.ThumbnailURL = nil
+		e.LinkURL = nil
+	}
+	occurred := time.Now()
+	e.OccurredAt = &occurred
+	e.Stamp()
+	return e
+}
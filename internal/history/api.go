@@ -0,0 +1,102 @@
+package history
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// Routes serves the store's history:
+//
+//	GET /       events, newest first, filtered by the query parameters
+//	            source, type (repeatable or comma separated), since, until
+//	            (RFC 3339 or a duration ago, e.g. 24h), q and limit.
+//	GET /{id}   a single event.
+func (s *Store) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/", s.handleList)
+	router.Get("/{id}", s.handleGet)
+	return router
+}
+
+func (s *Store) handleList(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, map[string]interface{}{"code": http.StatusBadRequest, "message": err.Error()})
+		return
+	}
+	records, err := s.List(q)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query event history.")
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]interface{}{"code": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+	render.JSON(w, r, map[string]interface{}{"events": records})
+}
+
+func (s *Store) handleGet(w http.ResponseWriter, r *http.Request) {
+	record, err := s.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query event history.")
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, map[string]interface{}{"code": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+	if record == nil {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, map[string]interface{}{"code": http.StatusNotFound, "message": "event not found"})
+		return
+	}
+	render.JSON(w, r, record)
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	v := r.URL.Query()
+	q := Query{
+		Source: v.Get("source"),
+		Text:   v.Get("q"),
+	}
+	for _, param := range v["type"] {
+		for _, name := range strings.Split(param, ",") {
+			t, err := event.ParseEventType(strings.TrimSpace(name))
+			if err != nil {
+				return q, err
+			}
+			q.Types = append(q.Types, t)
+		}
+	}
+	var err error
+	if q.Since, err = parseTime(v.Get("since")); err != nil {
+		return q, fmt.Errorf("since: %w", err)
+	}
+	if q.Until, err = parseTime(v.Get("until")); err != nil {
+		return q, fmt.Errorf("until: %w", err)
+	}
+	if l := v.Get("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil {
+			return q, fmt.Errorf("limit: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// parseTime accepts an RFC 3339 timestamp, or a duration before now.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,437 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite" // registers the sqlite driver, in pure Go, so releases needn't use cgo
+
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+)
+
+const (
+	pruneInterval = time.Hour
+
+	// Records are written from a single goroutine, so that recording never
+	// blocks the broker. Records arriving while the buffer is full are
+	// dropped, counted, and reported by the writer once it catches up.
+	writeBufferLength = 1000
+
+	// Upper bound on the rows scanned by a text search, which is matched in
+	// Go against the decoded event.
+	maxScan = 10000
+
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	id           TEXT PRIMARY KEY,
+	received_at  INTEGER NOT NULL,
+	source       TEXT NOT NULL,
+	event_type   INTEGER NOT NULL,
+	body         BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_received_at ON events (received_at);
+CREATE TABLE IF NOT EXISTS deliveries (
+	event_id     TEXT NOT NULL,
+	sink         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	error        TEXT NOT NULL,
+	at           INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS deliveries_event_id ON deliveries (event_id);
+`
+
+type Opts struct {
+	Path      string
+	MaxAge    time.Duration       // Events older than MaxAge are pruned. Zero keeps events regardless of age.
+	MaxEvents int                 // Only the newest MaxEvents events are kept. Zero keeps any number.
+	Keyring   *encryption.Keyring // Seals each event. Source, type and receipt time are kept in plaintext for filtering.
+}
+
+// Store records events received by the SinkManager, and the outcome of
+// delivering them to each sink, for later inspection.
+type Store struct {
+	db   *sql.DB
+	opts Opts
+
+	writes  chan func() error
+	done    chan struct{}
+	stopped chan struct{}
+
+	dropped  atomic.Uint64 // records dropped as the write buffer was full
+	reported uint64        // of dropped, those logged, only used by run
+}
+
+// Record is an event, along with what became of it.
+type Record struct {
+	Event      event.Event     `json:"event"`
+	Deliveries []sink.Delivery `json:"deliveries"`
+}
+
+type Query struct {
+	Source string
+	Types  []event.EventType
+	Since  time.Time // Inclusive, on ReceivedAt. Zero for no lower bound.
+	Until  time.Time // Exclusive, on ReceivedAt. Zero for no upper bound.
+	Text   string    // Case-insensitive match against title, description and metadata values.
+	Limit  int       // Defaults to DefaultLimit, capped at MaxLimit.
+}
+
+// Open opens, or creates, the store at opts.Path, prunes it, and starts the
+// writer.
+func Open(opts Opts) (*Store, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("history: path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o700); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+opts.Path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %s: %w", opts.Path, err)
+	}
+
+	s := &Store{
+		db:      db,
+		opts:    opts,
+		writes:  make(chan func() error, writeBufferLength),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if err := s.rekey(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %s: %w", opts.Path, err)
+	}
+	if err := s.prune(); err != nil {
+		log.Error().Err(err).Msg("Failed to prune event history.")
+	}
+	go s.run()
+	return s, nil
+}
+
+// Close writes any buffered records, and closes the store.
+func (s *Store) Close() error {
+	close(s.done)
+	<-s.stopped
+	return s.db.Close()
+}
+
+func (s *Store) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case write := <-s.writes:
+			if err := write(); err != nil {
+				log.Error().Err(err).Msg("Failed to write event history.")
+			}
+			s.reportDropped()
+		case <-ticker.C:
+			if err := s.prune(); err != nil {
+				log.Error().Err(err).Msg("Failed to prune event history.")
+			}
+		case <-s.done:
+			for {
+				select {
+				case write := <-s.writes:
+					if err := write(); err != nil {
+						log.Error().Err(err).Msg("Failed to write event history.")
+					}
+				default:
+					s.reportDropped()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Store) enqueue(write func() error) {
+	select {
+	case s.writes <- write:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many records have been dropped, as the write buffer
+// was full.
+func (s *Store) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// reportDropped logs any records dropped since it last did.
+func (s *Store) reportDropped() {
+	total := s.dropped.Load()
+	if total == s.reported {
+		return
+	}
+	log.Warn().
+		Uint64("dropped", total-s.reported).
+		Uint64("dropped_total", total).
+		Msg("Event history write buffer was full, dropped records.")
+	s.reported = total
+}
+
+// RecordEvent implements sink.Recorder.
+func (s *Store) RecordEvent(e event.Event) {
+	s.enqueue(func() error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if body, err = s.opts.Keyring.Seal(body); err != nil {
+			return err
+		}
+		_, err = s.db.Exec(
+			`INSERT OR REPLACE INTO events (id, received_at, source, event_type, body) VALUES (?, ?, ?, ?, ?)`,
+			e.ID, e.ReceivedAt.UnixNano(), e.Source, int(e.EventType), body,
+		)
+		return err
+	})
+}
+
+// RecordDelivery implements sink.Recorder.
+func (s *Store) RecordDelivery(d sink.Delivery) {
+	s.enqueue(func() error {
+		_, err := s.db.Exec(
+			`INSERT INTO deliveries (event_id, sink, status, error, at) VALUES (?, ?, ?, ?, ?)`,
+			d.EventID, d.Sink, string(d.Status), d.Error, d.At.UnixNano(),
+		)
+		return err
+	})
+}
+
+func (s *Store) prune() error {
+	if s.opts.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.opts.MaxAge).UnixNano()
+		if _, err := s.db.Exec(`DELETE FROM events WHERE received_at < ?`, cutoff); err != nil {
+			return err
+		}
+	}
+	if s.opts.MaxEvents > 0 {
+		_, err := s.db.Exec(
+			`DELETE FROM events WHERE id NOT IN (SELECT id FROM events ORDER BY received_at DESC LIMIT ?)`,
+			s.opts.MaxEvents,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := s.db.Exec(`DELETE FROM deliveries WHERE event_id NOT IN (SELECT id FROM events)`)
+	return err
+}
+
+// List returns the events matching q, newest first.
+func (s *Store) List(q Query) ([]Record, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	var where []string
+	var args []interface{}
+	if q.Source != "" {
+		where = append(where, "source = ? COLLATE NOCASE")
+		args = append(args, q.Source)
+	}
+	if len(q.Types) > 0 {
+		placeholders := make([]string, len(q.Types))
+		for i, t := range q.Types {
+			placeholders[i] = "?"
+			args = append(args, int(t))
+		}
+		where = append(where, "event_type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "received_at >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "received_at < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+
+	query := `SELECT body FROM events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY received_at DESC LIMIT ?"
+	if q.Text != "" {
+		args = append(args, maxScan)
+	} else {
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	text := strings.ToLower(q.Text)
+	records := []Record{}
+	for rows.Next() && len(records) < limit {
+		var body []byte
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		e, err := s.decode(body)
+		if err != nil {
+			return nil, err
+		}
+		if text != "" && !matchText(e, text) {
+			continue
+		}
+		records = append(records, Record{Event: e})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].Deliveries, err = s.deliveries(records[i].Event.ID); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// Get returns the event with the provided ID, or nil if there is none.
+func (s *Store) Get(id string) (*Record, error) {
+	var body []byte
+	err := s.db.QueryRow(`SELECT body FROM events WHERE id = ?`, id).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.decode(body)
+	if err != nil {
+		return nil, err
+	}
+	r := &Record{Event: e}
+	if r.Deliveries, err = s.deliveries(id); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Store) decode(body []byte) (event.Event, error) {
+	var e event.Event
+	body, err := s.opts.Keyring.Open(body)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(body, &e)
+	return e, err
+}
+
+// rekey checks every stored event can be opened, and seals again any stored
+// in plaintext, or with a previous key, so that rotated keys can be retired.
+func (s *Store) rekey() error {
+	rows, err := s.db.Query(`SELECT id, body FROM events`)
+	if err != nil {
+		return err
+	}
+	updates := make(map[string][]byte)
+	for rows.Next() {
+		var id string
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			rows.Close()
+			return err
+		}
+		plaintext, err := s.opts.Keyring.Open(body)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("event %s: %w", id, err)
+		}
+		if s.opts.Keyring.NeedsRekey(body) {
+			if updates[id], err = s.opts.Keyring.Seal(plaintext); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for id, body := range updates {
+		if _, err := tx.Exec(`UPDATE events SET body = ? WHERE id = ?`, body, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Info().Int("events", len(updates)).Msg("Encrypted event history with the current key.")
+	return nil
+}
+
+func (s *Store) deliveries(id string) ([]sink.Delivery, error) {
+	rows, err := s.db.Query(`SELECT sink, status, error, at FROM deliveries WHERE event_id = ? ORDER BY at`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []sink.Delivery{}
+	for rows.Next() {
+		d := sink.Delivery{EventID: id}
+		var status string
+		var at int64
+		if err := rows.Scan(&d.Sink, &status, &d.Error, &at); err != nil {
+			return nil, err
+		}
+		d.Status = sink.DeliveryStatus(status)
+		d.At = time.Unix(0, at)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// matchText reports whether text, which must be lower case, appears in the
+// event's title, description or metadata values.
+func matchText(e event.Event, text string) bool {
+	if strings.Contains(strings.ToLower(e.Title), text) ||
+		strings.Contains(strings.ToLower(e.Description), text) {
+		return true
+	}
+	for _, m := range e.Metadata {
+		if strings.Contains(strings.ToLower(m.Value), text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+)
+
+func TestStoreRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s, err := Open(Opts{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := event.Sample(event.ObjectGrabbed)
+	s.RecordEvent(e)
+	s.RecordDelivery(sink.Delivery{EventID: e.ID, Sink: "discord", Status: sink.DeliveryHeld, Error: "coalescing", At: time.Now()})
+	s.RecordDelivery(sink.Delivery{EventID: e.ID, Sink: "discord", Status: sink.DeliveryDelivered, At: time.Now()})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened, as the records are written in the background.
+	if s, err = Open(Opts{Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r, err := s.Get(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.Event.Title != e.Title {
+		t.Fatalf("Get(%s) = %v, want the event", e.ID, r)
+	}
+	if len(r.Deliveries) != 2 || r.Deliveries[0].Status != sink.DeliveryHeld || r.Deliveries[1].Status != sink.DeliveryDelivered {
+		t.Errorf("deliveries = %v, want held, then delivered", r.Deliveries)
+	}
+}
+
+func TestStoreCountsDropped(t *testing.T) {
+	// Not started, so nothing drains the buffer.
+	s := &Store{writes: make(chan func() error, 1)}
+	for i := 0; i < 3; i++ {
+		s.RecordEvent(event.Sample(event.TestEvent))
+	}
+	if got := s.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+	s.reportDropped()
+	if s.reported != 2 {
+		t.Errorf("reported %d, want 2", s.reported)
+	}
+}
@@ -0,0 +1,209 @@
+// Package logging sets up zerolog's global logger, and loggers for
+// components whose levels can be set independently, and changed at runtime.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rtrox/informer/internal/redact"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+
+	// Default names the level of loggers without a level of their own.
+	Default = "default"
+	// Broker is the component which dedups and routes events to sinks.
+	Broker = "broker"
+	// Sources is the component which receives events from sources.
+	Sources = "sources"
+	// Sinks is the parent of each sink's component, named by Sink.
+	Sinks = "sinks"
+)
+
+// Sink returns the component name of the named sink, e.g. sinks/discord.
+// Its level defaults to the Sinks level.
+func Sink(name string) string {
+	return Sinks + "/" + name
+}
+
+// output lets the format be chosen after loggers have been created.
+type output struct {
+	mut sync.RWMutex // protects w
+	w   io.Writer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mut.RLock()
+	defer o.mut.RUnlock()
+	return o.w.Write(p)
+}
+
+func (o *output) set(w io.Writer) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.w = w
+}
+
+var (
+	out = &output{w: redact.NewWriter(os.Stderr)}
+
+	// levels holds an immutable map[string]zerolog.Level of the levels in
+	// effect, by component, replaced whole on change.
+	levels     atomic.Value
+	configured = map[string]zerolog.Level{Default: zerolog.InfoLevel}
+	overrides  = make(map[string]zerolog.Level)
+	levelMut   sync.Mutex // protects configured and overrides, and serializes changes to levels
+)
+
+func init() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	publish()
+	log.Logger = newLogger("")
+}
+
+// Setup selects the log format, FormatConsole or FormatJSON.
+func Setup(format string) error {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		out.set(redact.NewWriter(os.Stderr))
+	case FormatConsole:
+		out.set(redact.NewWriter(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}))
+	default:
+		return fmt.Errorf("unknown log format %q, expected console or json", format)
+	}
+	return nil
+}
+
+// For returns a logger for the component, e.g. Broker or Sink("discord").
+func For(component string) zerolog.Logger {
+	return newLogger(component)
+}
+
+func newLogger(component string) zerolog.Logger {
+	ctx := zerolog.New(out).With().Timestamp()
+	if component != "" {
+		ctx = ctx.Str("component", component)
+	}
+	return ctx.Logger().Hook(levelHook{component: component})
+}
+
+// levelHook discards events below the component's level. zerolog's global
+// level is kept at the lowest level of any component, so that events no
+// component would log are discarded before they're built.
+type levelHook struct {
+	component string
+}
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level < effectiveLevel(h.component) {
+		e.Discard()
+	}
+}
+
+// effectiveLevel returns the component's level, falling back to its parent
+// (sinks/discord to sinks), then to the default level.
+func effectiveLevel(component string) zerolog.Level {
+	set := levels.Load().(map[string]zerolog.Level)
+	for component != "" {
+		if l, ok := set[component]; ok {
+			return l
+		}
+		parent, _, ok := strings.Cut(component, "/")
+		if !ok {
+			break
+		}
+		component = parent
+	}
+	return set[Default]
+}
+
+// ParseLevel parses a level name, e.g. debug or warn.
+func ParseLevel(level string) (zerolog.Level, error) {
+	l, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil || level == "" {
+		return zerolog.NoLevel, fmt.Errorf("unknown log level %q, expected trace, debug, info, warn, error, fatal, panic or disabled", level)
+	}
+	return l, nil
+}
+
+// SetLevels replaces the configured level of every component. Default must
+// be set. Overrides set at runtime still take precedence.
+func SetLevels(set map[string]zerolog.Level) error {
+	if _, ok := set[Default]; !ok {
+		return fmt.Errorf("the %s log level must be set", Default)
+	}
+	levelMut.Lock()
+	defer levelMut.Unlock()
+	configured = copyLevels(set)
+	publish()
+	return nil
+}
+
+// Override sets a component's level at runtime, in place of its configured
+// level, or removes the override if level is zerolog.NoLevel.
+func Override(component string, level zerolog.Level) {
+	levelMut.Lock()
+	defer levelMut.Unlock()
+	if level == zerolog.NoLevel {
+		delete(overrides, component)
+	} else {
+		overrides[component] = level
+	}
+	publish()
+}
+
+// ResetOverrides restores every component's configured level.
+func ResetOverrides() {
+	levelMut.Lock()
+	defer levelMut.Unlock()
+	overrides = make(map[string]zerolog.Level)
+	publish()
+}
+
+// publish stores the configured levels with overrides applied, and lowers
+// zerolog's global level to the lowest of them. Callers must hold levelMut.
+func publish() {
+	set := copyLevels(configured)
+	for k, v := range overrides {
+		set[k] = v
+	}
+	lowest := zerolog.Disabled
+	for _, l := range set {
+		if l < lowest {
+			lowest = l
+		}
+	}
+	levels.Store(set)
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// Levels returns the levels in effect, by component, and the components
+// whose level is overridden at runtime.
+func Levels() (map[string]zerolog.Level, map[string]bool) {
+	levelMut.Lock()
+	defer levelMut.Unlock()
+	overridden := make(map[string]bool, len(overrides))
+	for k := range overrides {
+		overridden[k] = true
+	}
+	return copyLevels(levels.Load().(map[string]zerolog.Level)), overridden
+}
+
+func copyLevels(set map[string]zerolog.Level) map[string]zerolog.Level {
+	c := make(map[string]zerolog.Level, len(set))
+	for k, v := range set {
+		c[k] = v
+	}
+	return c
+}
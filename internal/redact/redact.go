@@ -0,0 +1,162 @@
+// Package redact keeps secrets resolved from the environment or secret
+// files out of logs.
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const Placeholder = "[REDACTED]"
+
+// Shorter values are too likely to appear in logs by coincidence to be
+// replaced wherever they appear.
+const minSecretLength = 6
+
+var secretKey = regexp.MustCompile(`(?i)(key|pass|secret|token|webhook|credential)`)
+
+var (
+	secrets   = make(map[string]bool)
+	replacer  = strings.NewReplacer()
+	secretMut sync.RWMutex // protects secrets and replacer
+)
+
+// IsSecretKey reports whether a config key likely holds a secret, e.g.
+// api-key or webhook_url.
+func IsSecretKey(key string) bool {
+	return secretKey.MatchString(key)
+}
+
+// Register records a secret, to be redacted wherever it appears.
+func Register(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secretMut.Lock()
+	defer secretMut.Unlock()
+	if secrets[value] {
+		return
+	}
+	secrets[value] = true
+	pairs := make([]string, 0, 2*len(secrets))
+	for s := range secrets {
+		pairs = append(pairs, s, Placeholder)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// String replaces registered secrets in s.
+func String(s string) string {
+	secretMut.RLock()
+	defer secretMut.RUnlock()
+	return replacer.Replace(s)
+}
+
+// Value returns a generic copy of v, e.g. a config struct, suitable for
+// logging: values of secret keys, and registered secrets, are redacted.
+func Value(v interface{}) interface{} {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return Placeholder
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return Placeholder
+	}
+	Node(&n)
+	var out interface{}
+	if err := n.Decode(&out); err != nil {
+		return Placeholder
+	}
+	return out
+}
+
+// Node redacts values of secret keys, and registered secrets, in place.
+func Node(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			Node(c)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if v.Kind == yaml.ScalarNode && v.Value != "" && IsSecretKey(k.Value) {
+				v.Value = Placeholder
+				v.Tag = "!!str"
+				v.Style = 0
+				continue
+			}
+			Node(v)
+		}
+	case yaml.ScalarNode:
+		n.Value = String(n.Value)
+	}
+}
+
+// Restore undoes Node's redaction of n, a copy of prev which may have been
+// edited, e.g. by a client of an API serving configs redacted: each value
+// still as Node left it is restored from the value at the same path in
+// prev.
+func Restore(n *yaml.Node, prev *yaml.Node) {
+	if n == nil || prev == nil {
+		return
+	}
+	if n.Kind == yaml.DocumentNode && prev.Kind == yaml.DocumentNode && len(n.Content) == 1 && len(prev.Content) == 1 {
+		Restore(n.Content[0], prev.Content[0])
+		return
+	}
+	switch {
+	case n.Kind == yaml.MappingNode && prev.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			p := mappingValue(prev, k.Value)
+			if p == nil {
+				continue
+			}
+			if v.Kind == yaml.ScalarNode && p.Kind == yaml.ScalarNode && IsSecretKey(k.Value) && v.Value == Placeholder {
+				*v = *p
+				continue
+			}
+			Restore(v, p)
+		}
+	case n.Kind == yaml.SequenceNode && prev.Kind == yaml.SequenceNode:
+		for i := 0; i < len(n.Content) && i < len(prev.Content); i++ {
+			Restore(n.Content[i], prev.Content[i])
+		}
+	case n.Kind == yaml.ScalarNode && prev.Kind == yaml.ScalarNode:
+		if strings.Contains(n.Value, Placeholder) && n.Value == String(prev.Value) {
+			*n = *prev
+		}
+	}
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+type writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a writer which redacts registered secrets before
+// writing to w, e.g. for log output.
+func NewWriter(w io.Writer) io.Writer {
+	return writer{w: w}
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
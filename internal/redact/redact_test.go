@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRestore(t *testing.T) {
+	const prev = `
+url: https://radarr.example
+api-key: abcdef123456
+headers:
+  - token: ghijkl789012
+`
+	tests := []struct {
+		name   string
+		edited func(redacted string) string // the redacted config, as a client edits it
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			edited: func(redacted string) string { return redacted },
+			want:   prev,
+		},
+		{
+			name: "secret replaced",
+			edited: func(string) string {
+				return "url: https://radarr.example\napi-key: newkey000000\nheaders:\n  - token: '" + Placeholder + "'\n"
+			},
+			want: "url: https://radarr.example\napi-key: newkey000000\nheaders:\n  - token: ghijkl789012\n",
+		},
+		{
+			name: "other value edited",
+			edited: func(string) string {
+				return "url: https://movies.example\napi-key: '" + Placeholder + "'\n"
+			},
+			want: "url: https://movies.example\napi-key: abcdef123456\n",
+		},
+		{
+			name: "new key left redacted",
+			edited: func(string) string {
+				return "password: '" + Placeholder + "'\n"
+			},
+			want: "password: '" + Placeholder + "'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p yaml.Node
+			if err := yaml.Unmarshal([]byte(prev), &p); err != nil {
+				t.Fatal(err)
+			}
+			var redacted yaml.Node
+			if err := yaml.Unmarshal([]byte(prev), &redacted); err != nil {
+				t.Fatal(err)
+			}
+			Node(&redacted)
+			b, err := yaml.Marshal(&redacted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var n yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.edited(string(b))), &n); err != nil {
+				t.Fatal(err)
+			}
+			Restore(&n, &p)
+
+			var got, want interface{}
+			if err := n.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotYAML, _ := yaml.Marshal(got)
+			wantYAML, _ := yaml.Marshal(want)
+			if string(gotYAML) != string(wantYAML) {
+				t.Errorf("restored:\n%s\nwant:\n%s", gotYAML, wantYAML)
+			}
+		})
+	}
+}
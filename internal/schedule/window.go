@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a daily time range, optionally restricted to some weekdays. A
+// window whose end is before its start runs past midnight, and belongs to
+// the day it starts on.
+type Window struct {
+	Days  []time.Weekday // Empty means every day.
+	Start time.Duration  // Offset from midnight.
+	End   time.Duration  // Offset from midnight.
+}
+
+// ParseWindow parses a window from "HH:MM" start and end times, and weekday
+// names (sun, mon, ... or full names).
+func ParseWindow(start string, end string, days []string) (Window, error) {
+	w := Window{}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return w, err
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, err
+	}
+	for _, d := range days {
+		key := strings.ToLower(d)
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		wd, ok := weekdays[key]
+		if !ok {
+			return w, fmt.Errorf("unknown weekday %q", d)
+		}
+		w.Days = append(w.Days, wd)
+	}
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w Window) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Window) wraps() bool {
+	return w.End <= w.Start
+}
+
+// Windows is a set of windows, evaluated in a timezone.
+type Windows struct {
+	Windows  []Window
+	Location *time.Location
+}
+
+func (ws Windows) loc() *time.Location {
+	if ws.Location == nil {
+		return time.Local
+	}
+	return ws.Location
+}
+
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// at returns the time offset from midnight of day, by wall clock, so that
+// windows keep their local times across DST changes.
+func at(day time.Time, offset time.Duration) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// Contains reports whether t falls in any window. An empty set of windows
+// contains every time.
+func (ws Windows) Contains(t time.Time) bool {
+	if len(ws.Windows) == 0 {
+		return true
+	}
+	t = t.In(ws.loc())
+	today := midnight(t)
+	yesterday := today.AddDate(0, 0, -1)
+
+	for _, w := range ws.Windows {
+		if w.onDay(today.Weekday()) && !t.Before(at(today, w.Start)) {
+			if w.wraps() || t.Before(at(today, w.End)) {
+				return true
+			}
+		}
+		if w.wraps() && w.onDay(yesterday.Weekday()) && t.Before(at(today, w.End)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if it falls in a window, or else the next time a window
+// opens. A zero time means no window will ever open.
+func (ws Windows) NextOpen(t time.Time) time.Time {
+	if ws.Contains(t) {
+		return t
+	}
+	t = t.In(ws.loc())
+	today := midnight(t)
+
+	var next time.Time
+	for d := 0; d <= 7; d++ {
+		day := today.AddDate(0, 0, d)
+		for _, w := range ws.Windows {
+			if !w.onDay(day.Weekday()) {
+				continue
+			}
+			start := at(day, w.Start)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
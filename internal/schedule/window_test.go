@@ -0,0 +1,40 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestWindowsContainsAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ParseWindow("09:00", "17:00", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := Windows{Windows: []Window{w}, Location: loc}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"before, spring forward", time.Date(2026, 3, 8, 8, 30, 0, 0, loc), false},
+		{"after start, spring forward", time.Date(2026, 3, 8, 9, 30, 0, 0, loc), true},
+		{"before end, spring forward", time.Date(2026, 3, 8, 16, 30, 0, 0, loc), true},
+		{"after end, spring forward", time.Date(2026, 3, 8, 17, 30, 0, 0, loc), false},
+		{"before, fall back", time.Date(2026, 11, 1, 8, 30, 0, 0, loc), false},
+		{"after start, fall back", time.Date(2026, 11, 1, 9, 30, 0, 0, loc), true},
+		{"after end, fall back", time.Date(2026, 11, 1, 17, 30, 0, 0, loc), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ws.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
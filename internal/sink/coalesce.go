@@ -0,0 +1,229 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+type CoalesceOpts struct {
+	Window  time.Duration // Deliver a group once no matching event has arrived for Window.
+	MaxWait time.Duration // Deliver a group no later than MaxWait after its first event. Defaults to 4x Window.
+	Fields  []string      // Event fields which group events, as for event.Fingerprint. Defaults to event.DefaultFingerprintFields.
+	Filter  Filter        // Events eligible for coalescing. Anything else is passed straight through.
+}
+
+type coalesceGroup struct {
+	events []event.Event
+	first  time.Time
+	timer  *time.Timer
+}
+
+// Coalescer wraps a sink, combining bursts of events which share a
+// fingerprint (e.g. each episode of an imported season) into a single event,
+// delivered via the sink's processor.
+type Coalescer struct {
+	inner   Sink
+	opts    CoalesceOpts
+	release ReleaseFunc
+
+	groups   map[string]*coalesceGroup
+	mut      sync.Mutex     // protects groups
+	flushing sync.WaitGroup // flushes in flight, from timers and Done
+}
+
+func NewCoalescer(inner Sink, opts CoalesceOpts) (*Coalescer, error) {
+	if opts.Window <= 0 {
+		return nil, fmt.Errorf("coalesce: window must be positive")
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = 4 * opts.Window
+	}
+	if opts.MaxWait < opts.Window {
+		return nil, fmt.Errorf("coalesce: max-wait must not be shorter than window")
+	}
+	if len(opts.Fields) == 0 {
+		opts.Fields = event.DefaultFingerprintFields
+	}
+	return &Coalescer{
+		inner:  inner,
+		opts:   opts,
+		groups: make(map[string]*coalesceGroup),
+	}, nil
+}
+
+// Start implements Holder.
+func (c *Coalescer) Start(release ReleaseFunc, log zerolog.Logger) error {
+	c.release = release
+	return startInner(c.inner, release, log)
+}
+
+func (c *Coalescer) ProcessEvent(e event.Event) error {
+	if !c.opts.Filter.Match(e) {
+		return c.inner.ProcessEvent(e)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	key := event.Fingerprint(e, c.opts.Fields)
+	now := time.Now()
+	g, ok := c.groups[key]
+	if !ok {
+		g = &coalesceGroup{first: now}
+		c.groups[key] = g
+		g.timer = time.AfterFunc(c.opts.Window, func() { c.flush(key) })
+	} else {
+		wait := c.opts.Window
+		if deadline := g.first.Add(c.opts.MaxWait); now.Add(wait).After(deadline) {
+			wait = deadline.Sub(now)
+		}
+		g.timer.Reset(wait)
+	}
+	g.events = append(g.events, e)
+	return HeldError{Reason: "coalescing"}
+}
+
+// flush delivers the group's coalesced event, and waits for the outcome,
+// which the processor records.
+func (c *Coalescer) flush(key string) {
+	c.mut.Lock()
+	g, ok := c.groups[key]
+	delete(c.groups, key)
+	if ok {
+		c.flushing.Add(1)
+	}
+	c.mut.Unlock()
+	if !ok {
+		return
+	}
+	defer c.flushing.Done()
+
+	ids := make([]string, len(g.events))
+	for i, e := range g.events {
+		ids[i] = e.ID
+	}
+	_ = releaseAndWait(c.release, Release{
+		Event: Coalesce(g.events),
+		To:    c.inner,
+		For:   ids,
+	})
+}
+
+// Done delivers any pending groups, waits for flushes already in flight,
+// and closes the wrapped sink.
+func (c *Coalescer) Done() {
+	c.mut.Lock()
+	keys := make([]string, 0, len(c.groups))
+	for key, g := range c.groups {
+		g.timer.Stop()
+		keys = append(keys, key)
+	}
+	c.mut.Unlock()
+
+	for _, key := range keys {
+		c.flush(key)
+	}
+	c.flushing.Wait()
+	c.inner.Done()
+}
+
+// Coalesce combines events into one, based on the first. Metadata present in
+// every event with the same value is kept, lists are merged, byte counts are
+// summed, and anything else which differs between events is dropped. A
+// single event is returned unchanged.
+func Coalesce(events []event.Event) event.Event {
+	if len(events) == 1 {
+		return events[0]
+	}
+
+	e := events[0]
+	e.ID = event.NewID()
+	for _, other := range events[1:] {
+		if other.Description != e.Description {
+			e.Description = fmt.Sprintf("%d events", len(events))
+			break
+		}
+	}
+
+	var metadata event.MetadataList
+	for _, m := range events[0].Metadata {
+		merged, ok := coalesceField(m, events[1:])
+		if ok {
+			metadata.AddField(merged)
+		}
+	}
+	metadata.AddInt("coalesced", "Events", int64(len(events)), true)
+	e.Metadata = metadata
+	return e
+}
+
+func coalesceField(m event.MetadataField, rest []event.Event) (event.MetadataField, bool) {
+	switch m.Type {
+	case event.MetadataTypeList:
+		items, _ := m.Raw.([]string)
+		seen := make(map[string]bool)
+		var merged []string
+		add := func(list []string) {
+			for _, item := range list {
+				if !seen[item] {
+					seen[item] = true
+					merged = append(merged, item)
+				}
+			}
+		}
+		add(items)
+		for _, e := range rest {
+			if other := e.Metadata.Get(m.Key); other != nil {
+				more, _ := other.Raw.([]string)
+				add(more)
+			}
+		}
+		m.Raw = merged
+		m.Value = joinListValue(m.Value, merged)
+		return m, true
+	case event.MetadataTypeBytes:
+		total, _ := m.Raw.(int64)
+		for _, e := range rest {
+			other := e.Metadata.Get(m.Key)
+			if other == nil {
+				return m, false
+			}
+			n, _ := other.Raw.(int64)
+			total += n
+		}
+		m.Raw = total
+		m.Value = event.FormatBytes(total)
+		return m, true
+	default:
+		for _, e := range rest {
+			other := e.Metadata.Get(m.Key)
+			if other == nil || other.Value != m.Value {
+				return m, false
+			}
+		}
+		return m, true
+	}
+}
+
+// joinListValue renders items one per line if the original display value
+// was multi-line, or items are phrases (e.g. episode titles), and comma
+// separated otherwise (e.g. genres).
+func joinListValue(original string, items []string) string {
+	sep := ", "
+	if strings.Contains(original, "\n") {
+		sep = "\n"
+	}
+	for _, item := range items {
+		if strings.Contains(item, " ") {
+			sep = "\n"
+			break
+		}
+	}
+	return strings.Join(items, sep)
+}
@@ -0,0 +1,88 @@
+package sink
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// closingSink fails deliveries once closed.
+type closingSink struct {
+	memorySink
+	closed bool
+}
+
+func (s *closingSink) ProcessEvent(e event.Event) error {
+	s.mut.Lock()
+	closed := s.closed
+	s.mut.Unlock()
+	if closed {
+		return errors.New("delivered after Done")
+	}
+	return s.memorySink.ProcessEvent(e)
+}
+
+func (s *closingSink) Done() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.closed = true
+}
+
+func TestCoalescerDelivery(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		stop   bool // stop the processor before the window passes
+	}{
+		{"flushed by the window", 20 * time.Millisecond, false},
+		{"flushed by Done", time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &closingSink{}
+			c, err := NewCoalescer(inner, CoalesceOpts{Window: tt.window, Fields: []string{"source"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := &memoryRecorder{}
+			p := NewSinkProcessor(c, 10)
+			p.recorder = recorder
+			p.Start(nil)
+
+			events := []event.Event{event.Sample(event.ObjectDownloaded), event.Sample(event.ObjectDownloaded)}
+			for _, e := range events {
+				p.enqueue(e)
+			}
+			if tt.stop {
+				eventually(t, "the events to be coalesced", func() bool {
+					c.mut.Lock()
+					defer c.mut.Unlock()
+					for _, g := range c.groups {
+						return len(g.events) == 2
+					}
+					return false
+				})
+			} else {
+				eventually(t, "the coalesced event", func() bool { return len(inner.delivered()) == 1 })
+			}
+			p.Done()
+			p.Wait()
+
+			delivered := inner.delivered()
+			if len(delivered) != 1 || delivered[0].Metadata.Get("coalesced") == nil {
+				t.Fatalf("delivered %v, want one coalesced event", delivered)
+			}
+			statuses := recorder.statuses()
+			for _, e := range events {
+				if statuses[e.ID] != DeliveryDelivered {
+					t.Errorf("event %s recorded as %q, want %q", e.ID, statuses[e.ID], DeliveryDelivered)
+				}
+			}
+			if stats := p.Stats(); stats.Held != 2 || stats.Delivered != 1 || stats.Failed != 0 {
+				t.Errorf("Held = %d, Delivered = %d, Failed = %d, want 2, 1 and 0", stats.Held, stats.Delivered, stats.Failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package sink
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+type DedupOpts struct {
+	Window time.Duration // Events matching an earlier fingerprint within Window are dropped. Zero disables deduplication.
+	Fields []string      // Event fields to fingerprint. Defaults to event.DefaultFingerprintFields.
+}
+
+// deduplicator remembers recent event fingerprints, in memory.
+type deduplicator struct {
+	window time.Duration
+	fields []string
+	seen   map[string]time.Time // fingerprint -> expiry
+	mut    sync.Mutex           // protects seen
+}
+
+func newDeduplicator(opts DedupOpts) *deduplicator {
+	if opts.Window <= 0 {
+		return nil
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = event.DefaultFingerprintFields
+	}
+	return &deduplicator{
+		window: opts.Window,
+		fields: fields,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// isDuplicate records the event, and reports whether an event with the same
+// fingerprint was already seen within the window. A nil deduplicator never
+// reports duplicates.
+func (d *deduplicator) isDuplicate(e event.Event) bool {
+	if d == nil {
+		return false
+	}
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	now := time.Now()
+	for fp, expires := range d.seen {
+		if now.After(expires) {
+			delete(d.seen, fp)
+		}
+	}
+
+	fp := event.Fingerprint(e, d.fields)
+	if _, ok := d.seen[fp]; ok {
+		return true
+	}
+	d.seen[fp] = now.Add(d.window)
+	return false
+}
@@ -0,0 +1,328 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/rtrox/informer/internal/atomicfile"
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/schedule"
+)
+
+const (
+	DigestSource          = "Informer"
+	DigestSourceEventType = "Digest"
+
+	// Keeps each group's title list within Discord's 1024 character
+	// field limit.
+	digestMaxFieldLength = 1000
+)
+
+var digestNouns = map[string]string{
+	"sonarr":  "episode",
+	"radarr":  "movie",
+	"readarr": "book",
+	"lidarr":  "album",
+}
+
+var digestVerbs = map[event.EventType]string{
+	event.ObjectAdded:       "added",
+	event.ObjectGrabbed:     "grabbed",
+	event.ObjectDownloaded:  "downloaded",
+	event.ObjectRenamed:     "renamed",
+	event.ObjectUpdated:     "updated",
+	event.ObjectCompleted:   "completed",
+	event.ObjectFailed:      "failed",
+	event.ObjectFileDeleted: "file deleted",
+	event.ObjectDeleted:     "deleted",
+	event.Informational:     "informational",
+	event.HealthIssue:       "health issue",
+	event.HealthRestored:    "health restored",
+	event.TestEvent:         "test",
+}
+
+type DigestOpts struct {
+	Schedule schedule.Schedule
+	Filter   Filter              // Events to buffer. Anything else is passed straight through.
+	Title    string              // Title of the summary event. Defaults to "Digest".
+	Path     string              // File in which buffered events are persisted across restarts.
+	Keyring  *encryption.Keyring // Seals the persisted buffer. Nil leaves it in plaintext.
+}
+
+// Digest wraps a sink, buffering matching events and delivering a single
+// summary event to the wrapped sink on a schedule, via the sink's
+// processor.
+type Digest struct {
+	inner   Sink
+	opts    DigestOpts
+	release ReleaseFunc
+	log     zerolog.Logger
+
+	buffer    []event.Event
+	bufferMut sync.Mutex // protects buffer
+
+	done    chan struct{}
+	stopped chan struct{} // nil until Start
+}
+
+// NewDigest wraps inner. Events buffered before a restart are restored, and
+// the schedule started, by Start.
+func NewDigest(inner Sink, opts DigestOpts) (*Digest, error) {
+	if opts.Schedule == nil {
+		return nil, fmt.Errorf("digest: schedule is required")
+	}
+	if opts.Title == "" {
+		opts.Title = "Digest"
+	}
+	d := &Digest{
+		inner: inner,
+		opts:  opts,
+		log:   logging.For(logging.Sinks),
+		done:  make(chan struct{}),
+	}
+	// Fail early if the buffer can't be restored. It's read again by
+	// Start, as the sink's previous Digest may still be buffering.
+	if _, _, err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Start implements Holder.
+func (d *Digest) Start(release ReleaseFunc, log zerolog.Logger) error {
+	d.release = release
+	d.log = log
+	err := d.restore()
+	d.stopped = make(chan struct{})
+	go d.run()
+	return errors.Join(err, startInner(d.inner, release, log))
+}
+
+// restore restores the buffer persisted by the sink's previous Digest.
+func (d *Digest) restore() error {
+	buffer, rekey, err := d.load()
+	if err != nil {
+		return err
+	}
+	d.bufferMut.Lock()
+	defer d.bufferMut.Unlock()
+	d.buffer = buffer
+	if rekey {
+		return d.save()
+	}
+	return nil
+}
+
+func (d *Digest) ProcessEvent(e event.Event) error {
+	if !d.opts.Filter.Match(e) {
+		return d.inner.ProcessEvent(e)
+	}
+	if err := d.Add(e); err != nil {
+		return err
+	}
+	return HeldError{Reason: "buffered for digest"}
+}
+
+// Add buffers the event for the next summary, regardless of the filter.
+func (d *Digest) Add(e event.Event) error {
+	d.bufferMut.Lock()
+	defer d.bufferMut.Unlock()
+
+	d.buffer = append(d.buffer, e)
+	return d.save()
+}
+
+// Done stops the schedule, and closes the wrapped sink. Buffered events are
+// kept on disk for the next start.
+func (d *Digest) Done() {
+	close(d.done)
+	if d.stopped != nil {
+		<-d.stopped
+	}
+	d.inner.Done()
+}
+
+func (d *Digest) run() {
+	defer close(d.stopped)
+	for {
+		next := d.opts.Schedule.Next(time.Now())
+		if next.IsZero() {
+			<-d.done
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			if err := d.Flush(); err != nil {
+				d.log.Error().Err(err).Msg("Failed to deliver digest.")
+			}
+		case <-d.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Flush delivers a summary of the buffered events, if any, via the sink's
+// processor, and waits for the outcome. The buffer is only cleared once the
+// wrapped sink accepts the summary. Flush may only be called once started.
+func (d *Digest) Flush() error {
+	d.bufferMut.Lock()
+	events := d.buffer
+	d.bufferMut.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	err := releaseAndWait(d.release, Release{
+		Event: Summarize(d.opts.Title, events),
+		To:    d.inner,
+		For:   ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.bufferMut.Lock()
+	defer d.bufferMut.Unlock()
+	// Keep anything buffered while the summary was being delivered.
+	d.buffer = d.buffer[len(events):]
+	return d.save()
+}
+
+type digestGroup struct {
+	source    string
+	eventType event.EventType
+	titles    []string
+}
+
+// Summarize builds a single event describing events, grouped by Source and
+// EventType, e.g. "12 episodes downloaded, 3 movies added".
+func Summarize(title string, events []event.Event) event.Event {
+	groups := make(map[string]*digestGroup)
+	for _, e := range events {
+		key := e.Source + "\x00" + e.EventType.String()
+		g, ok := groups[key]
+		if !ok {
+			g = &digestGroup{source: e.Source, eventType: e.EventType}
+			groups[key] = g
+		}
+		g.titles = append(g.titles, e.Title)
+	}
+
+	sorted := make([]*digestGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].source != sorted[j].source {
+			return sorted[i].source < sorted[j].source
+		}
+		return sorted[i].eventType < sorted[j].eventType
+	})
+
+	s := event.Event{
+		EventType:       event.Informational,
+		Title:           title,
+		Source:          DigestSource,
+		SourceEventType: DigestSourceEventType,
+	}
+	s.Stamp()
+
+	var phrases []string
+	for _, g := range sorted {
+		phrases = append(phrases, g.phrase())
+		s.Metadata.AddField(event.MetadataField{
+			Key:   event.KeyFromName(g.source + " " + g.eventType.String()),
+			Name:  fmt.Sprintf("%s · %s (%d)", g.source, g.eventType, len(g.titles)),
+			Value: digestTitleList(g.titles),
+			Type:  event.MetadataTypeList,
+			Raw:   g.titles,
+		})
+	}
+	s.Description = strings.Join(phrases, ", ")
+	return s
+}
+
+func (g *digestGroup) phrase() string {
+	noun, ok := digestNouns[strings.ToLower(g.source)]
+	if !ok {
+		noun = g.source + " event"
+	}
+	if len(g.titles) != 1 {
+		noun += "s"
+	}
+	verb, ok := digestVerbs[g.eventType]
+	if !ok {
+		verb = strings.ToLower(g.eventType.String())
+	}
+	return fmt.Sprintf("%d %s %s", len(g.titles), noun, verb)
+}
+
+func digestTitleList(titles []string) string {
+	var b strings.Builder
+	for i, t := range titles {
+		line := "• " + t + "\n"
+		more := fmt.Sprintf("…and %d more", len(titles)-i)
+		if b.Len()+len(line)+len(more) > digestMaxFieldLength {
+			b.WriteString(more)
+			return b.String()
+		}
+		b.WriteString(line)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// load reads the persisted buffer, and whether it should be saved again,
+// sealed with the current key.
+func (d *Digest) load() ([]event.Event, bool, error) {
+	if d.opts.Path == "" {
+		return nil, false, nil
+	}
+	b, err := os.ReadFile(d.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("digest: %w", err)
+	}
+	plaintext, err := d.opts.Keyring.Open(b)
+	if err != nil {
+		return nil, false, fmt.Errorf("digest: %s: %w", d.opts.Path, err)
+	}
+	var buffer []event.Event
+	if err := json.Unmarshal(plaintext, &buffer); err != nil {
+		return nil, false, fmt.Errorf("digest: %s: %w", d.opts.Path, err)
+	}
+	return buffer, d.opts.Keyring.NeedsRekey(b), nil
+}
+
+// save persists the buffer. Callers must hold bufferMut.
+func (d *Digest) save() error {
+	if d.opts.Path == "" {
+		return nil
+	}
+	b, err := json.Marshal(d.buffer)
+	if err != nil {
+		return err
+	}
+	if b, err = d.opts.Keyring.Seal(b); err != nil {
+		return err
+	}
+	return atomicfile.Write(d.opts.Path, b)
+}
@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// everySchedule fires every interval.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// neverSchedule never fires.
+type neverSchedule struct{}
+
+func (neverSchedule) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+// memorySink keeps what it's delivered.
+type memorySink struct {
+	events []event.Event
+	mut    sync.Mutex
+}
+
+func (s *memorySink) ProcessEvent(e event.Event) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *memorySink) Done() {}
+
+func (s *memorySink) delivered() []event.Event {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]event.Event(nil), s.events...)
+}
+
+// memoryRecorder keeps the deliveries recorded.
+type memoryRecorder struct {
+	deliveries []Delivery
+	mut        sync.Mutex
+}
+
+func (r *memoryRecorder) RecordEvent(event.Event) {}
+
+func (r *memoryRecorder) RecordDelivery(d Delivery) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.deliveries = append(r.deliveries, d)
+}
+
+// statuses returns the last status recorded for each event ID.
+func (r *memoryRecorder) statuses() map[string]DeliveryStatus {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	statuses := make(map[string]DeliveryStatus)
+	for _, d := range r.deliveries {
+		statuses[d.EventID] = d.Status
+	}
+	return statuses
+}
+
+// eventually fails the test unless cond is met within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestDigestDeliversThroughProcessor(t *testing.T) {
+	inner := &memorySink{}
+	d, err := NewDigest(inner, DigestOpts{Schedule: everySchedule(20 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &memoryRecorder{}
+	p := NewSinkProcessor(d, 10)
+	p.recorder = recorder
+	p.Start(nil)
+	defer func() {
+		p.Done()
+		p.Wait()
+	}()
+
+	events := []event.Event{event.Sample(event.ObjectGrabbed), event.Sample(event.ObjectDownloaded)}
+	for _, e := range events {
+		p.enqueue(e)
+	}
+	eventually(t, "the digest", func() bool { return len(inner.delivered()) == 1 })
+
+	if got := inner.delivered()[0].SourceEventType; got != DigestSourceEventType {
+		t.Errorf("delivered %s, want a digest", got)
+	}
+	eventually(t, "deliveries to be recorded", func() bool {
+		statuses := recorder.statuses()
+		for _, e := range events {
+			if statuses[e.ID] != DeliveryDelivered {
+				return false
+			}
+		}
+		return true
+	})
+	if stats := p.Stats(); stats.Delivered == 0 {
+		t.Errorf("Delivered = %d, want the digest counted", stats.Delivered)
+	}
+}
+
+func TestDigestSurvivesReload(t *testing.T) {
+	opts := DigestOpts{Schedule: neverSchedule{}, Path: filepath.Join(t.TempDir(), "digest.json")}
+	newEntry := func() (*Digest, map[string]Entry) {
+		d, err := NewDigest(&memorySink{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d, map[string]Entry{"digest": {Sink: d}}
+	}
+	m := NewSinkManager(SinkManagerOpts{QueueLength: 10, SinkQueueLength: 10})
+	old, entries := newEntry()
+	m.UpdateSinks(entries)
+
+	// Built while the old Digest is still buffering, as on a config edit.
+	reloaded, entries := newEntry()
+	e := event.Sample(event.ObjectGrabbed)
+	m.broadcast(e)
+	eventually(t, "the event to be buffered", func() bool {
+		old.bufferMut.Lock()
+		defer old.bufferMut.Unlock()
+		return len(old.buffer) == 1
+	})
+	m.UpdateSinks(entries)
+
+	eventually(t, "the buffer to be restored", func() bool {
+		reloaded.bufferMut.Lock()
+		defer reloaded.bufferMut.Unlock()
+		return len(reloaded.buffer) == 1 && reloaded.buffer[0].ID == e.ID
+	})
+}
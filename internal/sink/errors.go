@@ -12,6 +12,18 @@ func (e InvalidConfigError) Error() string {
 	return "Invalid Sink Config"
 }
 
+// HeldError is returned by wrappers which didn't deliver an event to the
+// sink they wrap, but held it back to deliver later, or dropped it, as
+// configured. It isn't a failure.
+type HeldError struct {
+	Dropped bool   // The event was discarded, rather than held.
+	Reason  string // e.g. "buffered for digest"
+}
+
+func (e HeldError) Error() string {
+	return e.Reason
+}
+
 type UnknownEventError struct {
 	eventType event.EventType
 }
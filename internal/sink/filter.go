@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"strings"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// Filter selects events by type and source. Empty lists match everything.
+type Filter struct {
+	EventTypes []event.EventType
+	Sources    []string // Matched case-insensitively against event.Event.Source.
+}
+
+func (f Filter) Match(e event.Event) bool {
+	if len(f.EventTypes) > 0 {
+		found := false
+		for _, t := range f.EventTypes {
+			if t == e.EventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Sources) > 0 {
+		found := false
+		for _, s := range f.Sources {
+			if strings.EqualFold(s, e.Source) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
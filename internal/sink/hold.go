@@ -0,0 +1,48 @@
+package sink
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// Release is an event a wrapper held back, handed back to the sink's
+// processor for delivery, so that it is rate limited, and counted and
+// recorded, as any other delivery.
+type Release struct {
+	Event event.Event
+	To    Sink        // The sink the wrapper wraps.
+	For   []string    // IDs of the held events it delivers, for the history. Defaults to the event's own.
+	Done  func(error) // Optional, called with the outcome once delivered.
+}
+
+// ReleaseFunc hands a Release to the sink's processor, returning once the
+// processor has taken it. Wrappers may only call it between Start and the
+// end of their Done.
+type ReleaseFunc func(r Release)
+
+// Holder is implemented by wrappers which hold events back, to deliver them
+// later, e.g. Digest.
+type Holder interface {
+	// Start restores anything held before the sink was last closed, and
+	// begins releasing events with release. It is called by the sink's
+	// processor, once any earlier processor for the same sink has stopped,
+	// and log is the processor's. Wrappers start those they wrap.
+	Start(release ReleaseFunc, log zerolog.Logger) error
+}
+
+// startInner starts the sink a wrapper wraps, if it holds events too.
+func startInner(inner Sink, release ReleaseFunc, log zerolog.Logger) error {
+	if h, ok := inner.(Holder); ok {
+		return h.Start(release, log)
+	}
+	return nil
+}
+
+// releaseAndWait hands r to release, and waits for the outcome.
+func releaseAndWait(release ReleaseFunc, r Release) error {
+	result := make(chan error, 1)
+	r.Done = func(err error) { result <- err }
+	release(r)
+	return <-result
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+const defaultLifecycleTTL = 24 * time.Hour
+
+// MessageRef identifies a message previously delivered by a sink, in a form
+// only that sink understands (e.g. a Discord message ID).
+type MessageRef string
+
+// Updater is an optional interface for sinks which can edit a message they
+// have already delivered. When lifecycle tracking is enabled for the sink,
+// an ObjectGrabbed event carrying a CorrelationID is delivered with
+// SendEvent, and later events sharing that CorrelationID are delivered with
+// UpdateEvent rather than ProcessEvent.
+type Updater interface {
+	SendEvent(e event.Event) (MessageRef, error)
+	UpdateEvent(ref MessageRef, l Lifecycle, e event.Event) error
+}
+
+type LifecycleStep struct {
+	EventType       event.EventType
+	SourceEventType string
+	At              time.Time
+}
+
+// Lifecycle is the history of a correlated set of events, e.g. a Grab
+// followed by its Download.
+type Lifecycle struct {
+	Ref   MessageRef
+	Steps []LifecycleStep
+}
+
+// Elapsed returns the time between the first and last steps.
+func (l Lifecycle) Elapsed() time.Duration {
+	if len(l.Steps) < 2 {
+		return 0
+	}
+	return l.Steps[len(l.Steps)-1].At.Sub(l.Steps[0].At)
+}
+
+// Summary renders the lifecycle for display, e.g. "Grab → Download (4m)".
+func (l Lifecycle) Summary() string {
+	names := make([]string, 0, len(l.Steps))
+	for _, s := range l.Steps {
+		names = append(names, s.SourceEventType)
+	}
+	summary := strings.Join(names, " → ")
+	if len(l.Steps) > 1 {
+		summary += " (" + event.FormatDuration(l.Elapsed()) + ")"
+	}
+	return summary
+}
+
+type LifecycleOpts struct {
+	Enabled bool
+	TTL     time.Duration // How long to remember a lifecycle after its last event. Defaults to 24h.
+}
+
+type lifecycleEntry struct {
+	lifecycle Lifecycle
+	expires   time.Time
+}
+
+// lifecycleStore remembers in-progress lifecycles by CorrelationID, in
+// memory, forgetting them once their TTL passes without a new event.
+type lifecycleStore struct {
+	ttl     time.Duration
+	entries map[string]lifecycleEntry
+	mut     sync.Mutex // protects entries
+}
+
+func newLifecycleStore(ttl time.Duration) *lifecycleStore {
+	if ttl <= 0 {
+		ttl = defaultLifecycleTTL
+	}
+	return &lifecycleStore{
+		ttl:     ttl,
+		entries: make(map[string]lifecycleEntry),
+	}
+}
+
+func (s *lifecycleStore) get(id string) (Lifecycle, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok || time.Now().After(entry.expires) {
+		return Lifecycle{}, false
+	}
+	return entry.lifecycle, true
+}
+
+func (s *lifecycleStore) put(id string, l Lifecycle) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[id] = lifecycleEntry{lifecycle: l, expires: now.Add(s.ttl)}
+}
+
+func newLifecycleStep(e event.Event) LifecycleStep {
+	return LifecycleStep{
+		EventType:       e.EventType,
+		SourceEventType: e.SourceEventType,
+		At:              e.Time(),
+	}
+}
+
+func (s *lifecycleStore) delete(id string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	delete(s.entries, id)
+}
@@ -1,30 +1,65 @@
 package sink
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
 )
 
+var brokerLog = logging.For(logging.Broker)
+
 type SinkManager struct {
 	sinks           map[string]*sinkProcessor
+	lifecycles      map[string]*lifecycleStore // by sink name, kept across config reloads
 	in              chan event.Event
 	sinkMut         sync.RWMutex // protects sink map
 	wg              *sync.WaitGroup
 	sinkQueueLength int
+	dedup           *deduplicator
+	recorder        Recorder
+
+	received     atomic.Uint64
+	deduplicated atomic.Uint64
 }
 
 type SinkManagerOpts struct {
 	QueueLength     int
 	SinkQueueLength int
+	Dedup           DedupOpts
+	Recorder        Recorder // Optional, notified of every event and delivery outcome.
+}
+
+var ErrSinkNotFound = errors.New("sink not found")
+
+// SinkStatus describes a running sink.
+type SinkStatus struct {
+	Name   string         `json:"name"`
+	Type   string         `json:"type"`
+	Health string         `json:"health"`
+	Stats  ProcessorStats `json:"stats"`
+}
+
+// SinkManagerStats are counters since the SinkManager was created.
+type SinkManagerStats struct {
+	Queued       int    `json:"queued"`
+	Received     uint64 `json:"received"`
+	Deduplicated uint64 `json:"deduplicated"`
 }
 
 func NewSinkManager(opts SinkManagerOpts) *SinkManager {
 	return &SinkManager{
 		sinks:           make(map[string]*sinkProcessor),
+		lifecycles:      make(map[string]*lifecycleStore),
 		sinkQueueLength: opts.SinkQueueLength,
 		in:              make(chan event.Event, opts.QueueLength),
 		wg:              &sync.WaitGroup{},
+		dedup:           newDeduplicator(opts.Dedup),
+		recorder:        opts.Recorder,
 	}
 }
 
@@ -32,7 +67,115 @@ func (s *SinkManager) EnqueueEvent(e event.Event) {
 	s.in <- e
 }
 
-func (s *SinkManager) UpdateSinks(sinks map[string]Sink) {
+func (s *SinkManager) Stats() SinkManagerStats {
+	return SinkManagerStats{
+		Queued:       len(s.in),
+		Received:     s.received.Load(),
+		Deduplicated: s.deduplicated.Load(),
+	}
+}
+
+// SinkStats returns the stats of each sink's processor, by sink name.
+func (s *SinkManager) SinkStats() map[string]ProcessorStats {
+	s.sinkMut.RLock()
+	defer s.sinkMut.RUnlock()
+
+	stats := make(map[string]ProcessorStats, len(s.sinks))
+	for name, sink := range s.sinks {
+		stats[name] = sink.Stats()
+	}
+	return stats
+}
+
+// Sinks returns the status of each sink, sorted by name.
+func (s *SinkManager) Sinks() []SinkStatus {
+	s.sinkMut.RLock()
+	defer s.sinkMut.RUnlock()
+
+	statuses := make([]SinkStatus, 0, len(s.sinks))
+	for name, sink := range s.sinks {
+		stats := sink.Stats()
+		statuses = append(statuses, SinkStatus{
+			Name:   name,
+			Type:   sink.typ,
+			Health: stats.Health(),
+			Stats:  stats,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *SinkManager) processor(name string) (*sinkProcessor, error) {
+	s.sinkMut.RLock()
+	defer s.sinkMut.RUnlock()
+	p, ok := s.sinks[name]
+	if !ok {
+		return nil, ErrSinkNotFound
+	}
+	return p, nil
+}
+
+// PauseSink stops delivery to the sink, leaving events to wait in its queue.
+// Once the queue is full, further events for the sink are dropped.
+func (s *SinkManager) PauseSink(name string) error {
+	p, err := s.processor(name)
+	if err != nil {
+		return err
+	}
+	p.Pause()
+	brokerLog.Info().Str("sink", name).Msg("Sink paused.")
+	return nil
+}
+
+func (s *SinkManager) ResumeSink(name string) error {
+	p, err := s.processor(name)
+	if err != nil {
+		return err
+	}
+	p.Resume()
+	brokerLog.Info().Str("sink", name).Msg("Sink resumed.")
+	return nil
+}
+
+// DrainSink discards the events waiting in the sink's queue, returning how
+// many were discarded.
+func (s *SinkManager) DrainSink(name string) (int, error) {
+	p, err := s.processor(name)
+	if err != nil {
+		return 0, err
+	}
+	n := p.Drain()
+	brokerLog.Info().Str("sink", name).Int("drained", n).Msg("Sink queue drained.")
+	return n, nil
+}
+
+// SendTestEvent queues a synthetic TestEvent for a single sink, bypassing
+// dedup.
+func (s *SinkManager) SendTestEvent(name string) (event.Event, error) {
+	p, err := s.processor(name)
+	if err != nil {
+		return event.Event{}, err
+	}
+	e := event.Event{
+		EventType:       event.TestEvent,
+		Title:           "Test Event",
+		Description:     "A test event sent to sink " + name + ".",
+		Source:          "Informer",
+		SourceEventType: "Test",
+	}
+	e.Stamp()
+	if s.recorder != nil {
+		s.recorder.RecordEvent(e)
+	}
+	p.enqueue(e)
+	return e, nil
+}
+
+// UpdateSinks replaces the running sinks with sinks, by name. Sinks whose
+// Config is unchanged are left running, keeping their queues and whatever
+// they hold, and the entries built to replace them are closed unused.
+func (s *SinkManager) UpdateSinks(sinks map[string]Entry) {
 	s.sinkMut.Lock()
 	defer s.sinkMut.Unlock()
 
@@ -41,45 +184,114 @@ func (s *SinkManager) UpdateSinks(sinks map[string]Sink) {
 		_, ok := sinks[name]
 		if !ok {
 			sink.Done()
+			sink.Wait()
 			delete(s.sinks, name)
+			delete(s.lifecycles, name)
 		}
 	}
 
-	// Add any new sinks.
-	for name, sink := range sinks {
-		newSink := NewSinkProcessor(sink, s.sinkQueueLength)
-		newSink.Start(s.wg)
-		s.wg.Add(1)
+	// Add any new, or changed, sinks.
+	for name, entry := range sinks {
+		if oldSink, ok := s.sinks[name]; ok && entry.Config != "" && entry.Config == oldSink.config {
+			entry.Sink.Done()
+			continue
+		}
+		newSink := NewSinkProcessor(entry.Sink, s.sinkQueueLength)
+		newSink.name = name
+		newSink.log = logging.For(logging.Sink(name))
+		newSink.typ = entry.Type
+		newSink.recorder = s.recorder
+		if entry.Options.Lifecycle.Enabled {
+			if _, ok := s.lifecycles[name]; !ok {
+				s.lifecycles[name] = newLifecycleStore(entry.Options.Lifecycle.TTL)
+			}
+			newSink.lifecycles = s.lifecycles[name]
+		} else {
+			delete(s.lifecycles, name)
+		}
+		newSink.limiter = newTokenBucket(entry.Options.RateLimit)
+		newSink.filter = entry.Options.Filter
+		newSink.config = entry.Config
 
 		oldSink, ok := s.sinks[name]
 		if ok {
-			// If a sink with this name is alread registered,
-			// replace it first, and then close the old one.
-			defer oldSink.Done()
+			if oldSink.Paused() {
+				newSink.Pause()
+			}
+			// If a sink with this name is already registered, close
+			// it before starting the new one, so that the new one's
+			// wrappers restore whatever the old one's left held.
+			oldSink.Done()
+			oldSink.Wait()
 		}
 
+		s.wg.Add(1)
+		newSink.Start(s.wg)
 		s.sinks[name] = newSink
 	}
 }
 
-func (s *SinkManager) Start(done <-chan struct{}) {
+// broadcast duplicates the event into the queue of every sink it is routed
+// to, unless it repeats an event seen within the dedup window.
+func (s *SinkManager) broadcast(e event.Event) {
+	s.received.Add(1)
+	if s.recorder != nil {
+		s.recorder.RecordEvent(e)
+	}
+	if s.dedup.isDuplicate(e) {
+		s.deduplicated.Add(1)
+		if s.recorder != nil {
+			s.recorder.RecordDelivery(Delivery{
+				EventID: e.ID,
+				Status:  DeliveryDeduplicated,
+				At:      time.Now(),
+			})
+		}
+		brokerLog.Info().
+			Str("event_id", e.ID).
+			Str("source", e.Source).
+			Str("title", e.Title).
+			Uint64("deduplicated_total", s.deduplicated.Load()).
+			Msg("Dropped duplicate event.")
+		return
+	}
+
+	// Enqueue outside the lock, as enqueue blocks while a sink's queue is
+	// full, which would otherwise stall UpdateSinks and the admin API.
+	s.sinkMut.RLock()
+	routed := make([]*sinkProcessor, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		if sink.filter.Match(e) {
+			routed = append(routed, sink)
+		}
+	}
+	s.sinkMut.RUnlock()
+	for _, sink := range routed {
+		sink.enqueue(e)
+	}
+}
+
+// Start broadcasts queued events to the sinks until done is closed. The
+// returned channel is closed once every sink has flushed and stopped.
+func (s *SinkManager) Start(done <-chan struct{}) <-chan struct{} {
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		for {
 			select {
 			case e := <-s.in:
-				s.sinkMut.RLock()
-				defer s.sinkMut.RUnlock()
-				for _, sink := range s.sinks {
-					sink.In() <- e
-				}
+				s.broadcast(e)
 			case <-done:
+				s.sinkMut.RLock()
 				for _, sink := range s.sinks {
 					sink.Done()
 				}
+				s.sinkMut.RUnlock()
 				s.wg.Wait()
 				return
 			}
 
 		}
 	}()
+	return stopped
 }
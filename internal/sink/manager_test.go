@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// slowClosingSink takes a while to close, as sinks flushing on shutdown do.
+type slowClosingSink struct {
+	memorySink
+	closed atomic.Bool
+}
+
+func (s *slowClosingSink) Done() {
+	time.Sleep(50 * time.Millisecond)
+	s.closed.Store(true)
+}
+
+func TestSinkManagerStopped(t *testing.T) {
+	s := &slowClosingSink{}
+	m := NewSinkManager(SinkManagerOpts{QueueLength: 10, SinkQueueLength: 10})
+	m.UpdateSinks(map[string]Entry{"slow": {Sink: s}})
+
+	done := make(chan struct{})
+	stopped := m.Start(done)
+	m.EnqueueEvent(event.Sample(event.ObjectGrabbed))
+	eventually(t, "the event to be delivered", func() bool { return len(s.delivered()) == 1 })
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("SinkManager didn't stop")
+	}
+	if !s.closed.Load() {
+		t.Error("stopped was closed before the sink was")
+	}
+}
+
+// blockedSink doesn't return from ProcessEvent until unblocked.
+type blockedSink struct {
+	unblock chan struct{}
+}
+
+func (s *blockedSink) ProcessEvent(event.Event) error {
+	<-s.unblock
+	return nil
+}
+
+func (s *blockedSink) Done() {}
+
+func TestBroadcastDoesNotBlockUpdates(t *testing.T) {
+	blocked := &blockedSink{unblock: make(chan struct{})}
+	m := NewSinkManager(SinkManagerOpts{QueueLength: 10, SinkQueueLength: 1})
+	m.UpdateSinks(map[string]Entry{"blocked": {Sink: blocked}})
+	p, err := m.processor("blocked")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One event being delivered, one queued, and one waiting for room.
+	broadcasted := make(chan struct{})
+	go func() {
+		defer close(broadcasted)
+		for i := 0; i < 3; i++ {
+			m.broadcast(event.Sample(event.ObjectGrabbed))
+		}
+	}()
+	eventually(t, "the queue to fill", func() bool { return p.Stats().Queued == 1 })
+
+	// Removing the sink stops it, dropping the event waiting for room,
+	// though the sink's Wait lasts until its delivery finishes.
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		m.UpdateSinks(map[string]Entry{})
+	}()
+	select {
+	case <-broadcasted:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast didn't return once the sink was stopped")
+	}
+	if got := p.Stats().Dropped; got != 1 {
+		t.Errorf("Dropped = %d, want 1", got)
+	}
+
+	close(blocked.unblock)
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Fatal("UpdateSinks didn't return")
+	}
+}
+
+func TestUpdateSinksKeepsUnchanged(t *testing.T) {
+	m := NewSinkManager(SinkManagerOpts{QueueLength: 10, SinkQueueLength: 10})
+	m.UpdateSinks(map[string]Entry{
+		"kept":    {Sink: &closingSink{}, Config: "a"},
+		"changed": {Sink: &closingSink{}, Config: "a"},
+		"unknown": {Sink: &closingSink{}},
+	})
+	before := map[string]*sinkProcessor{}
+	for _, name := range []string{"kept", "changed", "unknown"} {
+		p, err := m.processor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[name] = p
+	}
+	m.PauseSink("kept")
+
+	unused := &closingSink{}
+	m.UpdateSinks(map[string]Entry{
+		"kept":    {Sink: unused, Config: "a"},
+		"changed": {Sink: &closingSink{}, Config: "b"},
+		"unknown": {Sink: &closingSink{}},
+		"added":   {Sink: &closingSink{}, Config: "a"},
+	})
+	for name, wantKept := range map[string]bool{"kept": true, "changed": false, "unknown": false} {
+		p, err := m.processor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := p == before[name]; kept != wantKept {
+			t.Errorf("%s kept = %t, want %t", name, kept, wantKept)
+		}
+	}
+	if !before["kept"].Paused() {
+		t.Error("kept sink was resumed")
+	}
+	if !unused.closed {
+		t.Error("the unused replacement for kept wasn't closed")
+	}
+	if _, err := m.processor("added"); err != nil {
+		t.Errorf("added: %v", err)
+	}
+}
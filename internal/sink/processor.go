@@ -1,23 +1,101 @@
 package sink
 
 import (
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
 
-	"github.com/rs/zerolog/log"
 	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
+)
+
+// Options configures how a sink's processor delivers events to it.
+type Options struct {
+	Filter    Filter // Events routed to the sink. Empty routes every event.
+	Lifecycle LifecycleOpts
+	RateLimit RateLimitOpts
+}
+
+// Entry is a sink, along with the options its processor should apply.
+type Entry struct {
+	Type    string // The registered sink type, for display.
+	Sink    Sink
+	Options Options
+	Config  string // The config the sink was built from, if known, so that reloads leave it running while it's unchanged.
+}
+
+// ProcessorStats are counters since the sink's processor was started.
+type ProcessorStats struct {
+	Queued          int           `json:"queued"`
+	QueueLength     int           `json:"queue_length"`
+	Paused          bool          `json:"paused"`
+	Delivered       uint64        `json:"delivered"`
+	Failed          uint64        `json:"failed"`
+	Held            uint64        `json:"held"`              // Held back by a digest, coalesce or schedule, to be delivered later.
+	Dropped         uint64        `json:"dropped"`           // Drained, discarded while paused with a full queue, or outside the sink's schedule.
+	RateLimitWait   time.Duration `json:"rate_limit_wait"`   // Total time spent waiting on the rate limiter.
+	LastDeliveredAt *time.Time    `json:"last_delivered_at"` // Nil if nothing has been delivered.
+	LastFailedAt    *time.Time    `json:"last_failed_at"`    // Nil if nothing has failed.
+	LastError       string        `json:"last_error,omitempty"`
+}
+
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing" // The most recent delivery failed.
+	HealthPaused  = "paused"
 )
 
+// Health summarizes the stats as HealthOK, HealthFailing or HealthPaused.
+func (p ProcessorStats) Health() string {
+	switch {
+	case p.Paused:
+		return HealthPaused
+	case p.LastFailedAt != nil && (p.LastDeliveredAt == nil || p.LastFailedAt.After(*p.LastDeliveredAt)):
+		return HealthFailing
+	default:
+		return HealthOK
+	}
+}
+
 type sinkProcessor struct {
-	sink Sink
-	in   chan event.Event
-	done chan struct{}
+	name       string
+	typ        string
+	log        zerolog.Logger
+	sink       Sink
+	recorder   Recorder // nil unless history is enabled
+	in         chan event.Event
+	releases   chan Release // events handed back by wrappers, unbuffered
+	done       chan struct{}
+	stopped    chan struct{}   // closed once the sink is closed
+	lifecycles *lifecycleStore // nil unless lifecycle tracking is enabled
+	limiter    *tokenBucket    // nil unless rate limiting is enabled
+	filter     Filter
+	config     string // Entry.Config
+
+	resume   chan struct{} // non-nil while paused, closed to resume
+	pauseMut sync.Mutex    // protects resume
+
+	delivered     atomic.Uint64
+	failed        atomic.Uint64
+	held          atomic.Uint64
+	dropped       atomic.Uint64
+	rateLimitWait atomic.Int64
+
+	last    ProcessorStats // only the Last* fields are used
+	lastMut sync.Mutex     // protects last
 }
 
 func NewSinkProcessor(sink Sink, queueLength int) *sinkProcessor {
 	return &sinkProcessor{
-		sink: sink,
-		in:   make(chan event.Event, queueLength),
-		done: make(chan struct{}),
+		sink:     sink,
+		log:      logging.For(logging.Sinks),
+		in:       make(chan event.Event, queueLength),
+		releases: make(chan Release),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 }
 
@@ -25,32 +103,337 @@ func (s *sinkProcessor) Done() {
 	close(s.done)
 }
 
+// Wait blocks until the processor has stopped, and closed its sink.
+func (s *sinkProcessor) Wait() {
+	<-s.stopped
+}
+
 func (s *sinkProcessor) In() chan event.Event {
 	return s.in
 }
 
+func (s *sinkProcessor) Stats() ProcessorStats {
+	s.lastMut.Lock()
+	defer s.lastMut.Unlock()
+	return ProcessorStats{
+		Queued:          len(s.in),
+		QueueLength:     cap(s.in),
+		Paused:          s.Paused(),
+		Delivered:       s.delivered.Load(),
+		Failed:          s.failed.Load(),
+		Held:            s.held.Load(),
+		Dropped:         s.dropped.Load(),
+		RateLimitWait:   time.Duration(s.rateLimitWait.Load()),
+		LastDeliveredAt: s.last.LastDeliveredAt,
+		LastFailedAt:    s.last.LastFailedAt,
+		LastError:       s.last.LastError,
+	}
+}
+
+// enqueue adds the event to the queue, blocking while it is full. If the
+// processor is paused, events which don't fit are dropped instead, so that
+// a paused sink can't stall every other sink. Events for a processor which
+// has been stopped, e.g. replaced by a config edit, are dropped too.
+func (s *sinkProcessor) enqueue(e event.Event) {
+	if !s.Paused() {
+		select {
+		case s.in <- e:
+		case <-s.done:
+			s.dropped.Add(1)
+			s.log.Warn().Str("sink", s.name).Str("event_id", e.ID).Msg("Sink was stopped, dropping event.")
+			s.recordDrop(e, "sink stopped")
+		}
+		return
+	}
+	select {
+	case s.in <- e:
+	default:
+		s.dropped.Add(1)
+		s.log.Warn().Str("sink", s.name).Str("event_id", e.ID).Msg("Sink is paused and its queue is full, dropping event.")
+		s.recordDrop(e, "queue full while paused")
+	}
+}
+
+// Pause stops delivery, leaving events to wait in the queue.
+func (s *sinkProcessor) Pause() {
+	s.pauseMut.Lock()
+	defer s.pauseMut.Unlock()
+	if s.resume == nil {
+		s.resume = make(chan struct{})
+	}
+}
+
+func (s *sinkProcessor) Resume() {
+	s.pauseMut.Lock()
+	defer s.pauseMut.Unlock()
+	if s.resume != nil {
+		close(s.resume)
+		s.resume = nil
+	}
+}
+
+func (s *sinkProcessor) Paused() bool {
+	s.pauseMut.Lock()
+	defer s.pauseMut.Unlock()
+	return s.resume != nil
+}
+
+// waitWhilePaused blocks until the processor is resumed. Returns false if
+// the processor was stopped while waiting.
+func (s *sinkProcessor) waitWhilePaused() bool {
+	s.pauseMut.Lock()
+	resume := s.resume
+	s.pauseMut.Unlock()
+	if resume == nil {
+		return true
+	}
+	select {
+	case <-resume:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// Drain discards the events waiting in the queue, returning how many were
+// discarded.
+func (s *sinkProcessor) Drain() int {
+	n := 0
+	for {
+		select {
+		case e := <-s.in:
+			n++
+			s.dropped.Add(1)
+			s.recordDrop(e, "drained")
+		default:
+			return n
+		}
+	}
+}
+
 func (s *sinkProcessor) ProcessEvent(e event.Event) error {
 	if e.EventType == event.Unknown {
 		return NewUnknownEventError(e.EventType)
 	}
+	if u, ok := s.sink.(Updater); ok && s.lifecycles != nil && e.CorrelationID != "" {
+		return s.processLifecycleEvent(u, e)
+	}
 	return s.sink.ProcessEvent(e)
 }
 
+// processLifecycleEvent edits the message posted for an earlier event with
+// the same CorrelationID, if one is known. Otherwise grabs start a new
+// lifecycle, and anything else is delivered as usual. If the message can't
+// be edited, e.g. as it was deleted, the lifecycle is forgotten, and the
+// event is sent as a new message instead.
+func (s *sinkProcessor) processLifecycleEvent(u Updater, e event.Event) error {
+	if l, ok := s.lifecycles.get(e.CorrelationID); ok {
+		l.Steps = append(l.Steps, newLifecycleStep(e))
+		err := u.UpdateEvent(l.Ref, l, e)
+		if err == nil {
+			s.lifecycles.put(e.CorrelationID, l)
+			return nil
+		}
+		s.log.Warn().Err(err).Str("event_id", e.ID).Str("correlation_id", e.CorrelationID).Msg("Error updating lifecycle message, sending a new message instead.")
+		s.lifecycles.delete(e.CorrelationID)
+		_, err = u.SendEvent(e)
+		return err
+	}
+
+	if e.EventType != event.ObjectGrabbed {
+		return s.sink.ProcessEvent(e)
+	}
+	ref, err := u.SendEvent(e)
+	if err != nil {
+		return err
+	}
+	s.lifecycles.put(e.CorrelationID, Lifecycle{
+		Ref:   ref,
+		Steps: []LifecycleStep{newLifecycleStep(e)},
+	})
+	return nil
+}
+
+// waitForRateLimit blocks until the rate limiter allows another event,
+// leaving later events waiting in the queue. Returns false if the processor
+// was stopped while waiting.
+func (s *sinkProcessor) waitForRateLimit(e event.Event) bool {
+	wait := s.limiter.reserve()
+	if wait <= 0 {
+		return true
+	}
+	s.rateLimitWait.Add(int64(wait))
+	s.log.Debug().
+		Str("event_id", e.ID).
+		Dur("wait", wait).
+		Int("queued", len(s.in)).
+		Msg("Rate limited, waiting.")
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// release implements ReleaseFunc.
+func (s *sinkProcessor) release(r Release) {
+	s.releases <- r
+}
+
+// deliver hands e to the sink, and records the outcome.
+func (s *sinkProcessor) deliver(e event.Event) {
+	s.record(e, []string{e.ID}, s.ProcessEvent(e))
+}
+
+// deliverRelease hands an event a wrapper released to the sink it wraps,
+// and records the outcome for each of the held events it delivers.
+func (s *sinkProcessor) deliverRelease(r Release) {
+	err := r.To.ProcessEvent(r.Event)
+	ids := r.For
+	if len(ids) == 0 {
+		ids = []string{r.Event.ID}
+	}
+	s.record(r.Event, ids, err)
+	if r.Done != nil {
+		r.Done(err)
+	}
+}
+
+// record counts the outcome of delivering e, and records it in the history
+// for each of ids. Events a wrapper held back are recorded as held, or
+// dropped, and recorded as delivered only once the wrapper releases them.
+func (s *sinkProcessor) record(e event.Event, ids []string, err error) {
+	var held HeldError
+	if errors.As(err, &held) {
+		s.recordHeld(ids, held)
+		return
+	}
+
+	now := time.Now()
+	s.lastMut.Lock()
+	if err != nil {
+		s.failed.Add(1)
+		s.log.Error().Err(err).Str("event_id", e.ID).Msg("Error processing event.")
+		s.last.LastFailedAt = &now
+		s.last.LastError = err.Error()
+	} else {
+		s.delivered.Add(1)
+		s.last.LastDeliveredAt = &now
+	}
+	s.lastMut.Unlock()
+
+	if s.recorder == nil {
+		return
+	}
+	for _, id := range ids {
+		d := Delivery{
+			EventID: id,
+			Sink:    s.name,
+			Status:  DeliveryDelivered,
+			At:      now,
+		}
+		if err != nil {
+			d.Status = DeliveryFailed
+			d.Error = err.Error()
+		}
+		s.recorder.RecordDelivery(d)
+	}
+}
+
+func (s *sinkProcessor) recordHeld(ids []string, held HeldError) {
+	status := DeliveryHeld
+	if held.Dropped {
+		status = DeliveryDropped
+		s.dropped.Add(1)
+	} else {
+		s.held.Add(1)
+	}
+	if s.recorder == nil {
+		return
+	}
+	for _, id := range ids {
+		s.recorder.RecordDelivery(Delivery{
+			EventID: id,
+			Sink:    s.name,
+			Status:  status,
+			Error:   held.Reason,
+			At:      time.Now(),
+		})
+	}
+}
+
+func (s *sinkProcessor) recordDrop(e event.Event, reason string) {
+	if s.recorder == nil {
+		return
+	}
+	s.recorder.RecordDelivery(Delivery{
+		EventID: e.ID,
+		Sink:    s.name,
+		Status:  DeliveryDropped,
+		Error:   reason,
+		At:      time.Now(),
+	})
+}
+
+// Start delivers queued events, and those released by the sink's
+// wrappers, until Done. Any earlier processor for the same sink must have
+// stopped, so that the wrappers restore what it left held.
 func (s *sinkProcessor) Start(wg *sync.WaitGroup) {
 	go func() {
 		if wg != nil {
 			defer wg.Done()
 		}
+		defer close(s.stopped)
+		defer s.stop()
+		if h, ok := s.sink.(Holder); ok {
+			if err := h.Start(s.release, s.log); err != nil {
+				s.log.Error().Err(err).Msg("Failed to restore held events.")
+			}
+		}
 		for {
+			if !s.waitWhilePaused() {
+				return
+			}
 			select {
 			case e := <-s.in:
-				if err := s.ProcessEvent(e); err != nil {
-					log.Error().Err(err).Msg("Error processing event.")
+				// Paused while waiting for this event.
+				if !s.waitWhilePaused() || !s.waitForRateLimit(e) {
+					return
+				}
+				s.deliver(e)
+			case r := <-s.releases:
+				if !s.waitWhilePaused() || !s.waitForRateLimit(r.Event) {
+					// The wrapper has let go of it, so deliver it
+					// regardless.
+					s.deliverRelease(r)
+					return
 				}
+				s.deliverRelease(r)
 			case <-s.done:
-				s.sink.Done()
 				return
 			}
 		}
 	}()
 }
+
+// stop closes the sink, still delivering anything its wrappers release as
+// they close, e.g. pending coalesced events, though no longer rate limited.
+func (s *sinkProcessor) stop() {
+	closed := make(chan struct{})
+	go func() {
+		s.sink.Done()
+		close(closed)
+	}()
+	for {
+		select {
+		case r := <-s.releases:
+			s.deliverRelease(r)
+		case <-closed:
+			return
+		}
+	}
+}
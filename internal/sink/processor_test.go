@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// updaterSink records what it was asked to deliver, failing updates if
+// updateErr is set.
+type updaterSink struct {
+	sent      []event.Event
+	updated   []event.Event
+	updateErr error
+}
+
+func (s *updaterSink) ProcessEvent(e event.Event) error {
+	_, err := s.SendEvent(e)
+	return err
+}
+
+func (s *updaterSink) SendEvent(e event.Event) (MessageRef, error) {
+	s.sent = append(s.sent, e)
+	return MessageRef(e.ID), nil
+}
+
+func (s *updaterSink) UpdateEvent(_ MessageRef, _ Lifecycle, e event.Event) error {
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	s.updated = append(s.updated, e)
+	return nil
+}
+
+func (s *updaterSink) Done() {}
+
+func TestProcessLifecycleEvent(t *testing.T) {
+	grabbed := event.Sample(event.ObjectGrabbed)
+	downloaded := event.Sample(event.ObjectDownloaded)
+	completed := event.Sample(event.ObjectCompleted)
+
+	tests := []struct {
+		name        string
+		updateErr   error
+		wantSent    int
+		wantUpdated int
+	}{
+		{"updates the grab's message", nil, 1, 2},
+		{"sends new messages when updates fail", errors.New("unknown message"), 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &updaterSink{updateErr: tt.updateErr}
+			p := NewSinkProcessor(s, 1)
+			p.lifecycles = newLifecycleStore(0)
+			for _, e := range []event.Event{grabbed, downloaded, completed} {
+				if err := p.ProcessEvent(e); err != nil {
+					t.Fatalf("ProcessEvent(%s) = %v", e.EventType, err)
+				}
+			}
+			if len(s.sent) != tt.wantSent || len(s.updated) != tt.wantUpdated {
+				t.Errorf("sent %d and updated %d, want %d and %d", len(s.sent), len(s.updated), tt.wantSent, tt.wantUpdated)
+			}
+			if _, ok := p.lifecycles.get(grabbed.CorrelationID); ok != (tt.updateErr == nil) {
+				t.Errorf("lifecycle kept = %v, want %v", ok, tt.updateErr == nil)
+			}
+		})
+	}
+}
+
+func TestRecordHeld(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status DeliveryStatus
+	}{
+		{"delivered", nil, DeliveryDelivered},
+		{"failed", errors.New("unavailable"), DeliveryFailed},
+		{"held", HeldError{Reason: "coalescing"}, DeliveryHeld},
+		{"dropped", HeldError{Dropped: true, Reason: "outside delivery window"}, DeliveryDropped},
+		{"wrapped", fmt.Errorf("wrapped: %w", HeldError{Reason: "buffered for digest"}), DeliveryHeld},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &memoryRecorder{}
+			p := NewSinkProcessor(&memorySink{}, 1)
+			p.recorder = recorder
+			e := event.Sample(event.ObjectGrabbed)
+			p.record(e, []string{e.ID}, tt.err)
+			if got := recorder.statuses()[e.ID]; got != tt.status {
+				t.Errorf("recorded %q, want %q", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"sync"
+	"time"
+)
+
+type RateLimitOpts struct {
+	Events int           // Events allowed per Per. Zero disables rate limiting.
+	Per    time.Duration // Defaults to one minute.
+	Burst  int           // Events which may be delivered back to back. Defaults to 1.
+}
+
+// tokenBucket is a token bucket rate limiter. Tokens refill continuously at
+// rate per second, up to burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mut    sync.Mutex // protects tokens and last
+}
+
+func newTokenBucket(opts RateLimitOpts) *tokenBucket {
+	if opts.Events <= 0 {
+		return nil
+	}
+	if opts.Per <= 0 {
+		opts.Per = time.Minute
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+	return &tokenBucket{
+		rate:   float64(opts.Events) / opts.Per.Seconds(),
+		burst:  float64(opts.Burst),
+		tokens: float64(opts.Burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, returning how long the caller must wait before
+// using it. A nil bucket never waits.
+func (b *tokenBucket) reserve() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,34 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryDelivered    DeliveryStatus = "delivered"    // Accepted by the sink.
+	DeliveryFailed       DeliveryStatus = "failed"       // The sink returned an error.
+	DeliveryDeduplicated DeliveryStatus = "deduplicated" // Dropped by the broker before fan-out, as a repeat.
+	DeliveryDropped      DeliveryStatus = "dropped"      // Discarded from the sink's queue, e.g. drained, or outside its schedule.
+	DeliveryHeld         DeliveryStatus = "held"         // Held back by a digest, coalesce or schedule, to be delivered later.
+)
+
+// Delivery is the outcome of handing an event to a sink.
+type Delivery struct {
+	EventID string         `json:"event_id"`
+	Sink    string         `json:"sink"` // Empty for outcomes decided by the broker, before fan-out.
+	Status  DeliveryStatus `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	At      time.Time      `json:"at"`
+}
+
+// Recorder is notified of every event the SinkManager receives, and every
+// delivery outcome. Implementations must be safe for concurrent use, and
+// should not block.
+type Recorder interface {
+	RecordEvent(e event.Event)
+	RecordDelivery(d Delivery)
+}
@@ -1,17 +1,34 @@
 package sink
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
 
 var (
 	sinkRegistryInstance *sinkRegistry
 )
 
-type sinkConstructorFunc func(yaml.Node) Sink
+// Opts are passed to every sink constructor, along with its config.
+type Opts struct {
+	// Log is the sink's component logger, whose level is set by
+	// log-levels and the sink's log-level.
+	Log zerolog.Logger
+}
+
+type sinkConstructorFunc func(yaml.Node, Opts) (Sink, error)
 type sinkValidatorFunc func(yaml.Node) error
 
 type SinkRegistryEntry struct {
 	Constructor sinkConstructorFunc
 	Validator   sinkValidatorFunc
+	// Config is the zero value of the type's config struct, against which
+	// config is checked for unknown keys, and from which its schema is
+	// generated. Nil accepts any config.
+	Config interface{}
 }
 
 type sinkRegistry struct {
@@ -35,11 +52,11 @@ func (s *sinkRegistry) registerSink(name string, entry SinkRegistryEntry) {
 	s.entries[name] = entry
 }
 
-func (s *sinkRegistry) getSink(name string, opts yaml.Node) Sink {
+func (s *sinkRegistry) getSink(name string, conf yaml.Node, opts Opts) (Sink, error) {
 	if entry, ok := s.entries[name]; ok {
-		return entry.Constructor(opts)
+		return entry.Constructor(conf, opts)
 	}
-	panic("sink not registered (name: " + name + ")")
+	return nil, fmt.Errorf("unknown sink type %q", name)
 }
 
 func (s *sinkRegistry) validateConfig(name string, opts yaml.Node) error {
@@ -53,10 +70,33 @@ func RegisterSink(name string, entry SinkRegistryEntry) {
 	getRegistry().registerSink(name, entry)
 }
 
-func MakeSink(name string, opts yaml.Node) Sink {
-	return getRegistry().getSink(name, opts)
+// MakeSink builds a sink of the registered type. The config should already
+// have been validated.
+func MakeSink(name string, conf yaml.Node, opts Opts) (Sink, error) {
+	return getRegistry().getSink(name, conf, opts)
 }
 
 func ValidateConfig(name string, opts yaml.Node) error {
 	return getRegistry().validateConfig(name, opts)
 }
+
+// Types returns the names of the registered sink types, sorted.
+func Types() []string {
+	entries := getRegistry().entries
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func IsRegistered(name string) bool {
+	_, ok := getRegistry().entries[name]
+	return ok
+}
+
+// ConfigSample returns the registered type's Config, which may be nil.
+func ConfigSample(name string) interface{} {
+	return getRegistry().entries[name].Config
+}
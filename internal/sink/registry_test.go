@@ -0,0 +1,86 @@
+package sink
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errBadValue = errors.New("bad value")
+
+type registryTestConfig struct {
+	Value string `yaml:"value"`
+}
+
+func init() {
+	RegisterSink("registry-test", SinkRegistryEntry{
+		Constructor: func(conf yaml.Node, _ Opts) (Sink, error) {
+			c := registryTestConfig{}
+			if err := conf.Decode(&c); err != nil {
+				return nil, err
+			}
+			if c.Value == "bad" {
+				return nil, errBadValue
+			}
+			return &memorySink{}, nil
+		},
+		Validator: func(conf yaml.Node) error {
+			c := registryTestConfig{}
+			if err := conf.Decode(&c); err != nil {
+				return err
+			}
+			if c.Value == "bad" {
+				return errBadValue
+			}
+			return nil
+		},
+		Config: registryTestConfig{},
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		config  string
+		wantErr string
+	}{
+		{"valid", "registry-test", "value: good\n", ""},
+		{"bad value", "registry-test", "value: bad\n", errBadValue.Error()},
+		{"wrong kind", "registry-test", "value: [a, b]\n", "cannot unmarshal"},
+		{"unknown type", "registry-tset", "value: good\n", `unknown sink type "registry-tset"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.config), &doc); err != nil {
+				t.Fatal(err)
+			}
+			conf := *doc.Content[0]
+			s, err := MakeSink(tt.typ, conf, Opts{})
+			if tt.wantErr == "" {
+				if err != nil || s == nil {
+					t.Fatalf("MakeSink() = %v, %v, want a sink", s, err)
+				}
+				s.Done()
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("MakeSink() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if tt.typ != "registry-test" {
+				return
+			}
+			if err := ValidateConfig(tt.typ, conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateConfig() = %v, want it to agree with MakeSink", err)
+			}
+		})
+	}
+
+	if !IsRegistered("registry-test") || IsRegistered("registry-tset") {
+		t.Error("IsRegistered doesn't match the registered types")
+	}
+	if _, ok := ConfigSample("registry-test").(registryTestConfig); !ok {
+		t.Errorf("ConfigSample() = %T, want registryTestConfig", ConfigSample("registry-test"))
+	}
+}
@@ -2,10 +2,11 @@ package sinks
 
 import (
 	"context"
+	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 
 	"github.com/disgoorg/disgo/discord"
@@ -15,47 +16,86 @@ import (
 
 	"github.com/rtrox/informer/internal/event"
 	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/templates"
 )
 
 func init() {
 	sink.RegisterSink("discord-webhook", sink.SinkRegistryEntry{
 		Constructor: NewDiscord,
+		Validator:   ValidateDiscordConfig,
+		Config:      DiscordConfig{},
 	})
 }
 
 type DiscordConfig struct {
-	WebhookURL string `yaml:"webhook_url"`
+	WebhookURL string           `yaml:"webhook_url"`
+	Templates  templates.Config `yaml:"templates"`
 }
 
 type DiscordWebhook struct {
-	baseCtx context.Context
-	cancel  context.CancelFunc
-	client  webhook.Client
+	baseCtx   context.Context
+	cancel    context.CancelFunc
+	client    webhook.Client
+	templates *templates.Set
 }
 
-func NewDiscord(conf yaml.Node) sink.Sink {
+func NewDiscord(conf yaml.Node, _ sink.Opts) (sink.Sink, error) {
 	c := DiscordConfig{}
 	if err := conf.Decode(&c); err != nil {
-		log.Error().Err(err).Msg("Failed to decode Discord config")
+		return nil, fmt.Errorf("discord: %w", err)
 	}
 
-	parts := strings.Split(c.WebhookURL, "/")
-	// TODO: validate URL format during ValidateConfig
-
-	idStr := parts[len(parts)-2]
-	id, err := snowflake.Parse(idStr)
+	id, token, err := parseDiscordWebhookURL(c.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("discord: %w", err)
+	}
+	tmpl, err := c.Templates.Parse()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to parse Discord webhook ID")
+		return nil, fmt.Errorf("discord: %w", err)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	return &DiscordWebhook{
-		baseCtx: ctx,
-		cancel:  cancel,
-		client: webhook.New(
-			id,
-			parts[len(parts)-1],
-		),
+		baseCtx:   ctx,
+		cancel:    cancel,
+		client:    webhook.New(id, token),
+		templates: tmpl,
+	}, nil
+}
+
+func ValidateDiscordConfig(conf yaml.Node) error {
+	c := DiscordConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return err
 	}
+	if _, _, err := parseDiscordWebhookURL(c.WebhookURL); err != nil {
+		return err
+	}
+	_, err := c.Templates.Parse()
+	return err
+}
+
+// parseDiscordWebhookURL returns the ID and token of a webhook URL, e.g.
+// https://discord.com/api/webhooks/<id>/<token>.
+func parseDiscordWebhookURL(raw string) (snowflake.ID, string, error) {
+	if raw == "" {
+		return 0, "", fmt.Errorf("webhook_url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return 0, "", fmt.Errorf("webhook_url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return 0, "", fmt.Errorf("webhook_url: expected an https URL, e.g. https://discord.com/api/webhooks/<id>/<token>")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "webhooks" || parts[len(parts)-1] == "" {
+		return 0, "", fmt.Errorf("webhook_url: expected a path of the form /api/webhooks/<id>/<token>")
+	}
+	id, err := snowflake.Parse(parts[len(parts)-2])
+	if err != nil {
+		return 0, "", fmt.Errorf("webhook_url: invalid webhook ID: %w", err)
+	}
+	return id, parts[len(parts)-1], nil
 }
 
 func (d *DiscordWebhook) Done() {
@@ -85,11 +125,25 @@ func (d *DiscordWebhook) EventColor(e event.Event) int {
 	}[e.EventType]
 }
 
-func (d *DiscordWebhook) eventToEmbed(event event.Event) discord.Embed {
+func (d *DiscordWebhook) eventToEmbed(event event.Event) (*discord.EmbedBuilder, error) {
+	msg, err := d.templates.Render(event, templates.Message{
+		Title: event.Title,
+		Body:  event.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	e := discord.NewEmbedBuilder()
 	e.SetColor(d.EventColor(event))
-	e.SetTitle(event.Title)
-	e.SetDescription(event.Description)
+	e.SetTitle(msg.Title)
+	e.SetDescription(msg.Body)
+	if msg.Footer != "" {
+		e.SetFooterText(msg.Footer)
+	}
+	if t := event.Time(); !t.IsZero() {
+		e.SetTimestamp(t)
+	}
 	if event.ThumbnailURL != nil {
 		e.SetThumbnail(*event.ThumbnailURL)
 	}
@@ -101,20 +155,52 @@ func (d *DiscordWebhook) eventToEmbed(event event.Event) discord.Embed {
 	for _, m := range event.Metadata {
 		e.AddField(m.Name, m.Value, m.Inline)
 	}
-	return e.Build()
+	return e, nil
 }
 
 func (d *DiscordWebhook) ProcessEvent(e event.Event) error {
-	embed := d.eventToEmbed(e)
+	_, err := d.SendEvent(e)
+	return err
+}
+
+func (d *DiscordWebhook) SendEvent(e event.Event) (sink.MessageRef, error) {
+	embed, err := d.eventToEmbed(e)
+	if err != nil {
+		return "", err
+	}
 	msg := discord.NewWebhookMessageCreateBuilder().
-		SetEmbeds(embed).
+		SetEmbeds(embed.Build()).
 		Build()
 
 	ctx, cancel := context.WithTimeout(d.baseCtx, 5*time.Second)
 	defer cancel()
 
-	if _, err := d.client.CreateMessage(msg, rest.WithCtx(ctx)); err != nil {
+	m, err := d.client.CreateMessage(msg, rest.WithCtx(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sink.MessageRef(m.ID.String()), nil
+}
+
+// UpdateEvent replaces the embed of a previously sent message with one for
+// the new event, adding the lifecycle's progress and timing.
+func (d *DiscordWebhook) UpdateEvent(ref sink.MessageRef, l sink.Lifecycle, e event.Event) error {
+	id, err := snowflake.Parse(string(ref))
+	if err != nil {
+		return err
+	}
+	embed, err := d.eventToEmbed(e)
+	if err != nil {
 		return err
 	}
-	return nil
+	embed.AddField("Progress", l.Summary(), false)
+	msg := discord.NewWebhookMessageUpdateBuilder().
+		SetEmbeds(embed.Build()).
+		Build()
+
+	ctx, cancel := context.WithTimeout(d.baseCtx, 5*time.Second)
+	defer cancel()
+
+	_, err = d.client.UpdateMessage(id, msg, rest.WithCtx(ctx))
+	return err
 }
@@ -0,0 +1,35 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/rtrox/informer/internal/sink"
+)
+
+func TestNewDiscord(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", "webhook_url: https://discord.com/api/webhooks/123/token\n", ""},
+		{"with templates", "webhook_url: https://discord.com/api/webhooks/123/token\ntemplates:\n  default:\n    title: '{{ .Title }}'\n", ""},
+		{"missing url", "", "discord: webhook_url is required"},
+		{"http url", "webhook_url: http://discord.com/api/webhooks/123/token\n", "discord: webhook_url: expected an https URL"},
+		{"short path", "webhook_url: https://discord.com/api/webhooks/123\n", "discord: webhook_url: expected a path of the form /api/webhooks/<id>/<token>"},
+		{"root path", "webhook_url: https://discord.com/\n", "discord: webhook_url: expected a path of the form"},
+		{"bad id", "webhook_url: https://discord.com/api/webhooks/abc/token\n", "discord: webhook_url: invalid webhook ID"},
+		{"bad template", "webhook_url: https://discord.com/api/webhooks/123/token\ntemplates:\n  default:\n    title: '{{ .Title'\n", "unclosed action"},
+		{"wrong kind", "webhook_url: [a]\n", "discord: yaml: unmarshal errors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := yamlNode(t, tt.config)
+			s, err := NewDiscord(conf, sink.Opts{})
+			checkConstructor(t, s, err, tt.wantErr)
+			if err := ValidateDiscordConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateDiscordConfig() = %v, want it to agree with NewDiscord", err)
+			}
+		})
+	}
+}
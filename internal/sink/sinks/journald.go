@@ -0,0 +1,156 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/templates"
+)
+
+func init() {
+	sink.RegisterSink("journald", sink.SinkRegistryEntry{
+		Constructor: NewJournald,
+		Validator:   ValidateJournaldConfig,
+		Config:      JournaldConfig{},
+	})
+}
+
+const journaldDefaultSocket = "/run/systemd/journal/socket"
+
+type JournaldConfig struct {
+	Socket    string           `yaml:"socket"`
+	AppName   string           `yaml:"app-name"`
+	Templates templates.Config `yaml:"templates"`
+}
+
+// Journald writes events to the systemd journal using its native protocol,
+// so that Source, SourceEventType and Metadata are kept as queryable fields
+// (e.g. `journalctl INFORMER_SOURCE=Sonarr`).
+type Journald struct {
+	socket    string
+	appName   string
+	templates *templates.Set
+
+	mut  sync.Mutex // protects conn
+	conn *net.UnixConn
+}
+
+func NewJournald(conf yaml.Node, _ sink.Opts) (sink.Sink, error) {
+	c := JournaldConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	j := &Journald{
+		socket:  c.Socket,
+		appName: c.AppName,
+	}
+	if j.socket == "" {
+		j.socket = journaldDefaultSocket
+	}
+	if j.appName == "" {
+		j.appName = "informer"
+	}
+	tmpl, err := c.Templates.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	j.templates = tmpl
+	return j, nil
+}
+
+func ValidateJournaldConfig(conf yaml.Node) error {
+	c := JournaldConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return err
+	}
+	_, err := c.Templates.Parse()
+	return err
+}
+
+func (j *Journald) ProcessEvent(e event.Event) error {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	if j.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.socket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+
+	msg, err := plainTextMessage(j.templates, e)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	writeJournaldField(&b, "MESSAGE", msg)
+	writeJournaldField(&b, "PRIORITY", fmt.Sprintf("%d", syslogSeverity(e.EventType)))
+	writeJournaldField(&b, "SYSLOG_IDENTIFIER", j.appName)
+	writeJournaldField(&b, "INFORMER_EVENT_ID", e.ID)
+	writeJournaldField(&b, "INFORMER_EVENT_TYPE", e.EventType.String())
+	if e.CorrelationID != "" {
+		writeJournaldField(&b, "INFORMER_CORRELATION_ID", e.CorrelationID)
+	}
+	writeJournaldField(&b, "INFORMER_SOURCE", e.Source)
+	if e.SourceInstance != "" {
+		writeJournaldField(&b, "INFORMER_SOURCE_INSTANCE", e.SourceInstance)
+	}
+	writeJournaldField(&b, "INFORMER_SOURCE_EVENT_TYPE", e.SourceEventType)
+	for _, m := range e.Metadata {
+		writeJournaldField(&b, "INFORMER_METADATA_"+journaldFieldName(m.Key), m.Value)
+	}
+
+	if _, err := j.conn.Write(b.Bytes()); err != nil {
+		j.conn.Close()
+		j.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (j *Journald) Done() {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	if j.conn != nil {
+		j.conn.Close()
+		j.conn = nil
+	}
+}
+
+// journaldFieldName converts a metadata key into a valid journal field name:
+// uppercase ASCII letters, digits and underscores.
+func journaldFieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// writeJournaldField serializes a field in the journal native protocol.
+// Values containing newlines use the length-prefixed binary form.
+func writeJournaldField(b *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,30 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/rtrox/informer/internal/sink"
+)
+
+func TestNewJournald(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"defaults", "", ""},
+		{"valid", "socket: /run/systemd/journal/socket\napp-name: informer\n", ""},
+		{"bad template", "templates:\n  default:\n    title: '{{ .Title'\n", "unclosed action"},
+		{"wrong kind", "app-name: [a]\n", "journald: yaml: unmarshal errors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := yamlNode(t, tt.config)
+			s, err := NewJournald(conf, sink.Opts{})
+			checkConstructor(t, s, err, tt.wantErr)
+			if err := ValidateJournaldConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateJournaldConfig() = %v, want it to agree with NewJournald", err)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package sinks
 
 import (
-	"github.com/rs/zerolog/log"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
 	"github.com/rtrox/informer/internal/event"
 	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/templates"
 	"gopkg.in/yaml.v3"
 )
 
@@ -11,24 +15,171 @@ func init() {
 	sink.RegisterSink("log", sink.SinkRegistryEntry{
 		Constructor: NewLog,
 		Validator:   ValidateLogConfig,
+		Config:      LogConfig{},
 	})
 }
 
+const logDefaultMessage = "Event Received."
+
+// Fields which can be selected for output, keyed by their JSON name.
+var logFields = map[string]func(*zerolog.Event, event.Event) *zerolog.Event{
+	"id": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("id", e.ID)
+	},
+	"received_at": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Time("received_at", e.ReceivedAt)
+	},
+	"occurred_at": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		if e.OccurredAt == nil {
+			return z
+		}
+		return z.Time("occurred_at", *e.OccurredAt)
+	},
+	"correlation_id": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("correlation_id", e.CorrelationID)
+	},
+	"source_instance": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("source_instance", e.SourceInstance)
+	},
+	"type": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("type", e.EventType.String())
+	},
+	"title": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("title", e.Title)
+	},
+	"description": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("description", e.Description)
+	},
+	"thumbnail_url": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return logOptionalStr(z, "thumbnail_url", e.ThumbnailURL)
+	},
+	"image_url": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return logOptionalStr(z, "image_url", e.ImageURL)
+	},
+	"link_url": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return logOptionalStr(z, "link_url", e.LinkURL)
+	},
+	"source": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("source", e.Source)
+	},
+	"source_event": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("source_event", e.SourceEventType)
+	},
+	"source_icon": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Str("source_icon", e.SourceIconURL)
+	},
+	"metadata": func(z *zerolog.Event, e event.Event) *zerolog.Event {
+		return z.Interface("metadata", e.Metadata)
+	},
+}
+
+func logOptionalStr(z *zerolog.Event, key string, v *string) *zerolog.Event {
+	if v == nil {
+		return z
+	}
+	return z.Str(key, *v)
+}
+
 type LogConfig struct {
-	// TODO: not used yet.
-	Level string `yaml:"level"`
+	Level     string            `yaml:"level"`     // Level to log events at, defaults to info.
+	Fields    []string          `yaml:"fields"`    // Event fields to include, by JSON name. Defaults to the whole event.
+	Levels    map[string]string `yaml:"levels"`    // Per EventType level overrides, e.g. HealthIssue: warn.
+	Template  string            `yaml:"template"`  // Optional one-line message template, shorthand for templates.default.title.
+	Templates templates.Config  `yaml:"templates"` // Per EventType message templates. The title is used as the log message.
+}
+
+type Log struct {
+	log       zerolog.Logger
+	level     zerolog.Level
+	levels    map[event.EventType]zerolog.Level
+	fields    []string
+	templates *templates.Set
 }
 
-type Log struct{}
+func NewLog(conf yaml.Node, opts sink.Opts) (sink.Sink, error) {
+	l := &Log{
+		log:    opts.Log,
+		level:  zerolog.InfoLevel,
+		levels: make(map[event.EventType]zerolog.Level),
+	}
 
-func NewLog(_ yaml.Node) sink.Sink {
-	return &Log{}
+	c := LogConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return nil, fmt.Errorf("log: %w", err)
+	}
+	if err := l.configure(c); err != nil {
+		return nil, fmt.Errorf("log: %w", err)
+	}
+	return l, nil
 }
 
-func (_ *Log) ProcessEvent(e event.Event) error {
-	log.Info().
-		Interface("event", e).
-		Msg("Event Received.")
+func (l *Log) configure(c LogConfig) error {
+	if c.Level != "" {
+		level, err := zerolog.ParseLevel(c.Level)
+		if err != nil {
+			return err
+		}
+		l.level = level
+	}
+
+	for name, lvl := range c.Levels {
+		t, err := event.ParseEventType(name)
+		if err != nil {
+			return err
+		}
+		level, err := zerolog.ParseLevel(lvl)
+		if err != nil {
+			return err
+		}
+		l.levels[t] = level
+	}
+
+	for _, f := range c.Fields {
+		f = strings.ToLower(f)
+		if _, ok := logFields[f]; !ok {
+			return fmt.Errorf("unknown field %q", f)
+		}
+		l.fields = append(l.fields, f)
+	}
+
+	if c.Template != "" {
+		if c.Templates == nil {
+			c.Templates = templates.Config{}
+		}
+		def := c.Templates[templates.DefaultKey]
+		if def.Title != "" || def.TitleFile != "" {
+			return fmt.Errorf("template and templates.default.title are mutually exclusive")
+		}
+		def.Title = c.Template
+		c.Templates[templates.DefaultKey] = def
+	}
+	tmpl, err := c.Templates.Parse()
+	if err != nil {
+		return err
+	}
+	l.templates = tmpl
+	return nil
+}
+
+func (l *Log) ProcessEvent(e event.Event) error {
+	level := l.level
+	if override, ok := l.levels[e.EventType]; ok {
+		level = override
+	}
+
+	msg, err := l.templates.Render(e, templates.Message{Title: logDefaultMessage})
+	if err != nil {
+		return err
+	}
+
+	z := l.log.WithLevel(level)
+	if len(l.fields) == 0 {
+		z = z.Interface("event", e)
+	}
+	for _, f := range l.fields {
+		z = logFields[f](z, e)
+	}
+	z.Msg(msg.Title)
 	return nil
 }
 
@@ -37,5 +188,9 @@ func (s *Log) Done() {
 }
 
 func ValidateLogConfig(opts yaml.Node) error {
-	return nil
+	c := LogConfig{}
+	if err := opts.Decode(&c); err != nil {
+		return err
+	}
+	return (&Log{levels: make(map[event.EventType]zerolog.Level)}).configure(c)
 }
@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+)
+
+// yamlNode parses conf, returning its top level node, as constructors are
+// passed.
+func yamlNode(t *testing.T, conf string) yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(conf), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Content) == 0 {
+		return yaml.Node{}
+	}
+	return *doc.Content[0]
+}
+
+// checkConstructor checks that constructing a sink returns an error
+// containing wantErr, or a sink if wantErr is empty.
+func checkConstructor(t *testing.T, s sink.Sink, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil || s == nil {
+			t.Fatalf("got %v, %v, want a sink", s, err)
+		}
+		s.Done()
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("got error %v, want one containing %q", err, wantErr)
+	}
+	if s != nil {
+		t.Errorf("got a sink along with error %v, want nil", err)
+	}
+}
+
+func TestNewLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"defaults", "", ""},
+		{"valid", "level: warn\nfields: [title, type]\nlevels:\n  HealthIssue: error\ntemplate: '{{ .Title }}'\n", ""},
+		{"bad level", "level: loud\n", "log: Unknown Level String: 'loud'"},
+		{"bad field", "fields: [colour]\n", `log: unknown field "colour"`},
+		{"bad event type", "levels:\n  Explosion: error\n", "log: unknown event type: Explosion"},
+		{"template twice", "template: a\ntemplates:\n  default:\n    title: b\n", "log: template and templates.default.title are mutually exclusive"},
+		{"wrong kind", "fields: title\n", "log: yaml: unmarshal errors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := yamlNode(t, tt.config)
+			s, err := NewLog(conf, sink.Opts{})
+			checkConstructor(t, s, err, tt.wantErr)
+			if err := ValidateLogConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateLogConfig() = %v, want it to agree with NewLog", err)
+			}
+		})
+	}
+}
+
+func TestLogUsesSinkLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  zerolog.Level // the sink's component level
+		logged bool
+	}{
+		{"at the sink's level", zerolog.InfoLevel, true},
+		{"below the sink's level", zerolog.WarnLevel, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			conf := yamlNode(t, "level: info\ntemplate: '{{ .Title }}'\n")
+			s, err := NewLog(conf, sink.Opts{Log: zerolog.New(&out).Level(tt.level)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := event.Sample(event.ObjectGrabbed)
+			if err := s.ProcessEvent(e); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(out.String(), e.Title); got != tt.logged {
+				t.Errorf("logged = %v, want %v: %q", got, tt.logged, out.String())
+			}
+		})
+	}
+}
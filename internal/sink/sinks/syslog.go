@@ -0,0 +1,358 @@
+package sinks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+	"github.com/rtrox/informer/internal/templates"
+)
+
+func init() {
+	sink.RegisterSink("syslog", sink.SinkRegistryEntry{
+		Constructor: NewSyslog,
+		Validator:   ValidateSyslogConfig,
+		Config:      SyslogConfig{},
+	})
+}
+
+// Private Enterprise Number used for structured data IDs. 32473 is reserved
+// by IANA for documentation and examples (RFC 5612), which is the accepted
+// choice for software without a registered PEN.
+const syslogEnterpriseID = "32473"
+
+const (
+	syslogSeverityError   = 3
+	syslogSeverityWarning = 4
+	syslogSeverityNotice  = 5
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// Sockets probed, in order, when no network is configured.
+var syslogLocalSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+type SyslogTLSConfig struct {
+	CAFile             string `yaml:"ca-file"`
+	ServerName         string `yaml:"server-name"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify"`
+}
+
+type SyslogConfig struct {
+	Network   string           `yaml:"network"` // udp, tcp, tls, unix, unixgram, or empty for the local socket
+	Address   string           `yaml:"address"`
+	Facility  string           `yaml:"facility"`
+	AppName   string           `yaml:"app-name"`
+	Hostname  string           `yaml:"hostname"`
+	TLS       SyslogTLSConfig  `yaml:"tls"`
+	Templates templates.Config `yaml:"templates"`
+}
+
+type Syslog struct {
+	conf     SyslogConfig
+	facility int
+	hostname string
+	appName  string
+	procID   string
+
+	templates *templates.Set
+
+	mut    sync.Mutex // protects conn and stream
+	conn   net.Conn
+	stream bool // whether conn is a stream, rather than datagrams
+}
+
+func NewSyslog(conf yaml.Node, _ sink.Opts) (sink.Sink, error) {
+	if err := ValidateSyslogConfig(conf); err != nil {
+		return nil, err
+	}
+	c := SyslogConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+
+	s := &Syslog{
+		conf:     c,
+		facility: syslogFacilities["daemon"],
+		hostname: c.Hostname,
+		appName:  c.AppName,
+		procID:   fmt.Sprintf("%d", os.Getpid()),
+	}
+	if f, ok := syslogFacilities[strings.ToLower(c.Facility)]; ok {
+		s.facility = f
+	}
+	if s.hostname == "" {
+		if h, err := os.Hostname(); err == nil {
+			s.hostname = h
+		}
+	}
+	if s.appName == "" {
+		s.appName = "informer"
+	}
+	tmpl, err := c.Templates.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+	s.templates = tmpl
+	return s, nil
+}
+
+func ValidateSyslogConfig(conf yaml.Node) error {
+	c := SyslogConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return err
+	}
+	switch c.Network {
+	case "":
+	case "udp", "tcp", "tls", "unix", "unixgram":
+		if c.Address == "" {
+			return fmt.Errorf("syslog: address is required for network %q", c.Network)
+		}
+	default:
+		return fmt.Errorf("syslog: unsupported network %q", c.Network)
+	}
+	if _, ok := syslogFacilities[strings.ToLower(c.Facility)]; c.Facility != "" && !ok {
+		return fmt.Errorf("syslog: unknown facility %q", c.Facility)
+	}
+	_, err := c.Templates.Parse()
+	return err
+}
+
+func (s *Syslog) ProcessEvent(e event.Event) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	text, err := plainTextMessage(s.templates, e)
+	if err != nil {
+		return err
+	}
+	ts := e.Time()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	msg := s.format(e, text, ts)
+
+	// Retry once on a fresh connection, in case the remote end has
+	// restarted since the last event was sent.
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return err
+			}
+			s.stream = isStream(s.conn)
+		}
+		if _, err = s.conn.Write(s.frame(msg)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *Syslog) Done() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *Syslog) dial() (net.Conn, error) {
+	timeout := 5 * time.Second
+	switch s.conf.Network {
+	case "":
+		for _, path := range syslogLocalSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				if conn, err := net.DialTimeout(network, path, timeout); err == nil {
+					return conn, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("syslog: no local syslog socket found")
+	case "tls":
+		tlsConf, err := s.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", s.conf.Address, tlsConf)
+	default:
+		return net.DialTimeout(s.conf.Network, s.conf.Address, timeout)
+	}
+}
+
+func (s *Syslog) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName:         s.conf.TLS.ServerName,
+		InsecureSkipVerify: s.conf.TLS.InsecureSkipVerify,
+	}
+	if s.conf.TLS.CAFile != "" {
+		pem, err := os.ReadFile(s.conf.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("syslog: no certificates found in %s", s.conf.TLS.CAFile)
+		}
+		c.RootCAs = pool
+	}
+	return c, nil
+}
+
+// frame applies octet-counting framing (RFC 6587) on stream connections.
+// Datagram connections carry exactly one message per packet.
+func (s *Syslog) frame(msg string) []byte {
+	if s.stream {
+		return []byte(fmt.Sprintf("%d %s", len(msg), msg))
+	}
+	return []byte(msg)
+}
+
+// isStream reports whether conn is a stream, e.g. TCP, TLS or a unix stream
+// socket, going by the connection dialed rather than the configured
+// network, as the local socket may be either kind.
+func isStream(conn net.Conn) bool {
+	switch conn := conn.(type) {
+	case *net.UDPConn:
+		return false
+	case *net.UnixConn:
+		addr, ok := conn.RemoteAddr().(*net.UnixAddr)
+		return ok && addr != nil && addr.Net == "unix"
+	default:
+		return true
+	}
+}
+
+func syslogSeverity(t event.EventType) int {
+	switch t {
+	case event.HealthIssue:
+		return syslogSeverityWarning
+	case event.ObjectFailed:
+		return syslogSeverityError
+	default:
+		return syslogSeverityNotice
+	}
+}
+
+// plainTextMessage renders the event as a single line of plain text, for
+// sinks without a separate title or footer.
+func plainTextMessage(t *templates.Set, e event.Event) (string, error) {
+	m, err := t.Render(e, templates.Message{Title: e.Title, Body: e.Description})
+	if err != nil {
+		return "", err
+	}
+	msg := m.Title
+	for _, part := range []string{m.Body, m.Footer} {
+		if part != "" {
+			msg += ": " + part
+		}
+	}
+	return strings.ReplaceAll(msg, "\n", " "), nil
+}
+
+// format renders an RFC 5424 message for the event.
+func (s *Syslog) format(e event.Event, msg string, ts time.Time) string {
+	pri := s.facility*8 + syslogSeverity(e.EventType)
+
+	var sd strings.Builder
+	sd.WriteString("[informer@" + syslogEnterpriseID)
+	writeSDParam(&sd, "id", e.ID)
+	if e.CorrelationID != "" {
+		writeSDParam(&sd, "correlationID", e.CorrelationID)
+	}
+	writeSDParam(&sd, "source", e.Source)
+	if e.SourceInstance != "" {
+		writeSDParam(&sd, "sourceInstance", e.SourceInstance)
+	}
+	writeSDParam(&sd, "sourceEventType", e.SourceEventType)
+	writeSDParam(&sd, "eventType", e.EventType.String())
+	sd.WriteString("]")
+	if len(e.Metadata) > 0 {
+		sd.WriteString("[metadata@" + syslogEnterpriseID)
+		for _, m := range e.Metadata {
+			writeSDParam(&sd, m.Key, m.Value)
+		}
+		sd.WriteString("]")
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s %s \xef\xbb\xbf%s",
+		pri,
+		ts.Format(time.RFC3339Nano),
+		syslogHeaderField(s.hostname, 255),
+		syslogHeaderField(s.appName, 48),
+		syslogHeaderField(s.procID, 128),
+		syslogHeaderField(e.EventType.String(), 32),
+		sd.String(),
+		msg,
+	)
+}
+
+// syslogHeaderField returns a header field restricted to printable US-ASCII,
+// truncated to max, or the NILVALUE if empty.
+func syslogHeaderField(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "-"
+	}
+	if len(v) > max {
+		v = v[:max]
+	}
+	return v
+}
+
+// writeSDParam appends a PARAM-NAME="PARAM-VALUE" pair. Names are restricted
+// to printable US-ASCII excluding '=', ' ', ']' and '"', up to 32 characters.
+func writeSDParam(b *strings.Builder, name string, value string) {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		return
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	fmt.Fprintf(b, ` %s="%s"`, name, value)
+}
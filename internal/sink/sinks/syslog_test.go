@@ -0,0 +1,129 @@
+package sinks
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/sink"
+)
+
+func TestNewSyslog(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"local", "", ""},
+		{"udp", "network: udp\naddress: localhost:514\nfacility: local0\napp-name: informer\n", ""},
+		{"tls", "network: tls\naddress: logs.example:6514\ntls:\n  server-name: logs.example\n", ""},
+		{"missing address", "network: tcp\n", `syslog: address is required for network "tcp"`},
+		{"bad network", "network: sctp\naddress: localhost:514\n", `syslog: unsupported network "sctp"`},
+		{"bad facility", "facility: local9\n", `syslog: unknown facility "local9"`},
+		{"wrong kind", "tls: true\n", "yaml: unmarshal errors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := yamlNode(t, tt.config)
+			s, err := NewSyslog(conf, sink.Opts{})
+			checkConstructor(t, s, err, tt.wantErr)
+			if err := ValidateSyslogConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateSyslogConfig() = %v, want it to agree with NewSyslog", err)
+			}
+		})
+	}
+}
+
+func TestSyslogLocalSocketFraming(t *testing.T) {
+	e := event.Sample(event.ObjectGrabbed)
+	tests := []struct {
+		network string
+		listen  func(t *testing.T, path string) <-chan string // the first message received
+		framed  bool
+	}{
+		{"unixgram", listenUnixgram, false},
+		{"unix", listenUnix, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			// Short, as socket paths are limited to around 100 bytes.
+			dir, err := os.MkdirTemp("", "syslog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+			path := filepath.Join(dir, "log")
+			received := tt.listen(t, path)
+
+			sockets := syslogLocalSockets
+			syslogLocalSockets = []string{path}
+			t.Cleanup(func() { syslogLocalSockets = sockets })
+
+			s, err := NewSyslog(yamlNode(t, ""), sink.Opts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Done()
+			if err := s.ProcessEvent(e); err != nil {
+				t.Fatal(err)
+			}
+
+			var msg string
+			select {
+			case msg = <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no message received")
+			}
+			length, rest, _ := strings.Cut(msg, " ")
+			framed := strings.HasPrefix(rest, "<") && length == strconv.Itoa(len(rest))
+			if framed != tt.framed {
+				t.Errorf("framed = %t, want %t: %q", framed, tt.framed, msg)
+			}
+			if !strings.Contains(msg, e.Title) {
+				t.Errorf("message %q doesn't contain the event's title", msg)
+			}
+		})
+	}
+}
+
+func listenUnixgram(t *testing.T, path string) <-chan string {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	received := make(chan string, 1)
+	go func() {
+		b := make([]byte, 64<<10)
+		if n, err := conn.Read(b); err == nil {
+			received <- string(b[:n])
+		}
+	}()
+	return received
+}
+
+func listenUnix(t *testing.T, path string) <-chan string {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b := make([]byte, 64<<10)
+		if n, err := conn.Read(b); err == nil {
+			received <- string(b[:n])
+		}
+	}()
+	return received
+}
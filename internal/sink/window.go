@@ -0,0 +1,300 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/rtrox/informer/internal/atomicfile"
+	"github.com/rtrox/informer/internal/encryption"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
+	"github.com/rtrox/informer/internal/schedule"
+)
+
+// OutOfWindowAction is what a WindowedSink does with events arriving outside
+// its delivery windows.
+type OutOfWindowAction string
+
+const (
+	OutOfWindowDrop   OutOfWindowAction = "drop"   // Discard the event.
+	OutOfWindowHold   OutOfWindowAction = "hold"   // Deliver the event when the next window opens.
+	OutOfWindowDigest OutOfWindowAction = "digest" // Add the event to the sink's digest.
+)
+
+type WindowOpts struct {
+	Windows schedule.Windows
+	Action  OutOfWindowAction
+	Always  []event.EventType   // Event types delivered regardless of the windows, e.g. HealthIssue.
+	Digest  *Digest             // Required for OutOfWindowDigest.
+	Path    string              // File in which held events are persisted across restarts.
+	Keyring *encryption.Keyring // Seals the persisted events. Nil leaves them in plaintext.
+}
+
+// Releases which fail, e.g. as the sink is down, are retried with an
+// exponential backoff between these delays.
+const (
+	windowRetryMin = 30 * time.Second
+	windowRetryMax = 15 * time.Minute
+)
+
+// WindowedSink wraps a sink, only delivering events during its windows
+// (e.g. outside of quiet hours). Held events are released via the sink's
+// processor.
+type WindowedSink struct {
+	inner   Sink
+	opts    WindowOpts
+	release ReleaseFunc
+	log     zerolog.Logger
+
+	held      []event.Event
+	heldMut   sync.Mutex // protects held, timer and closed
+	timer     *time.Timer
+	closed    bool           // set by Done
+	failures  int            // consecutive releases which failed to deliver every event
+	releasing sync.WaitGroup // releases in flight
+
+	retryMin, retryMax time.Duration
+}
+
+// NewWindowedSink wraps inner. Events held before a restart are restored,
+// and released, by Start.
+func NewWindowedSink(inner Sink, opts WindowOpts) (*WindowedSink, error) {
+	switch opts.Action {
+	case OutOfWindowDrop, OutOfWindowHold:
+	case OutOfWindowDigest:
+		if opts.Digest == nil {
+			return nil, fmt.Errorf("schedule: action %q requires a digest", opts.Action)
+		}
+	default:
+		return nil, fmt.Errorf("schedule: unknown action %q", opts.Action)
+	}
+
+	w := &WindowedSink{
+		inner:    inner,
+		opts:     opts,
+		log:      logging.For(logging.Sinks),
+		retryMin: windowRetryMin,
+		retryMax: windowRetryMax,
+	}
+	// Fail early if held events can't be restored. They're read again by
+	// Start, as the sink's previous WindowedSink may still be holding.
+	if _, _, err := w.load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Start implements Holder.
+func (w *WindowedSink) Start(release ReleaseFunc, log zerolog.Logger) error {
+	w.release = release
+	w.log = log
+	return errors.Join(w.restore(), startInner(w.inner, release, log))
+}
+
+// restore restores the events held by the sink's previous WindowedSink,
+// and schedules their release.
+func (w *WindowedSink) restore() error {
+	held, rekey, err := w.load()
+	if err != nil {
+		return err
+	}
+	w.heldMut.Lock()
+	defer w.heldMut.Unlock()
+	w.held = held
+	if len(w.held) > 0 {
+		w.scheduleRelease()
+	}
+	if rekey {
+		return w.save()
+	}
+	return nil
+}
+
+func (w *WindowedSink) always(t event.EventType) bool {
+	for _, a := range w.opts.Always {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WindowedSink) ProcessEvent(e event.Event) error {
+	if w.always(e.EventType) || w.opts.Windows.Contains(time.Now()) {
+		return w.inner.ProcessEvent(e)
+	}
+
+	switch w.opts.Action {
+	case OutOfWindowDigest:
+		if err := w.opts.Digest.Add(e); err != nil {
+			return err
+		}
+		return HeldError{Reason: "buffered for digest, outside delivery window"}
+	case OutOfWindowHold:
+		w.heldMut.Lock()
+		defer w.heldMut.Unlock()
+		w.held = append(w.held, e)
+		w.scheduleRelease()
+		if err := w.save(); err != nil {
+			return err
+		}
+		return HeldError{Reason: "held until the delivery window opens"}
+	default:
+		w.log.Debug().Str("event_id", e.ID).Msg("Dropped event outside of delivery window.")
+		return HeldError{Dropped: true, Reason: "outside delivery window"}
+	}
+}
+
+// scheduleRelease arms the release timer for the next window opening, if
+// not already armed. After failed releases, it waits out the backoff
+// first, releasing at the first window opening after it. Callers must hold
+// heldMut.
+func (w *WindowedSink) scheduleRelease() {
+	if w.timer != nil || w.closed {
+		return
+	}
+	now := time.Now()
+	next := w.opts.Windows.NextOpen(now.Add(w.backoff()))
+	if next.IsZero() {
+		w.log.Warn().Msg("No delivery window will open, held events will not be delivered.")
+		return
+	}
+	w.timer = time.AfterFunc(next.Sub(now), w.releaseHeld)
+}
+
+// backoff returns how long to wait before releasing again, after the last
+// failures. Callers must hold heldMut.
+func (w *WindowedSink) backoff() time.Duration {
+	if w.failures == 0 {
+		return 0
+	}
+	d := w.retryMin
+	for i := 1; i < w.failures && d < w.retryMax; i++ {
+		d *= 2
+	}
+	if d > w.retryMax {
+		d = w.retryMax
+	}
+	return d
+}
+
+// releaseHeld releases held events, in the order they arrived, each only
+// forgotten once delivered. Any which fail are kept, and retried after a
+// backoff.
+func (w *WindowedSink) releaseHeld() {
+	w.heldMut.Lock()
+	w.timer = nil
+	if w.closed {
+		w.heldMut.Unlock()
+		return
+	}
+	held := append([]event.Event(nil), w.held...)
+	w.releasing.Add(1)
+	w.heldMut.Unlock()
+	defer w.releasing.Done()
+
+	failed := false
+	for _, e := range held {
+		w.heldMut.Lock()
+		closed := w.closed
+		w.heldMut.Unlock()
+		if closed {
+			// Keep the rest for the next start.
+			return
+		}
+		// Held again further in, e.g. by a digest, is as good as delivered.
+		err := releaseAndWait(w.release, Release{Event: e, To: w.inner})
+		var held HeldError
+		if err != nil && !errors.As(err, &held) {
+			failed = true
+			continue
+		}
+		w.heldMut.Lock()
+		w.forget(e.ID)
+		if err := w.save(); err != nil {
+			w.log.Error().Err(err).Msg("Failed to persist held events.")
+		}
+		w.heldMut.Unlock()
+	}
+
+	w.heldMut.Lock()
+	defer w.heldMut.Unlock()
+	if failed {
+		w.failures++
+	} else {
+		w.failures = 0
+	}
+	if len(w.held) > 0 {
+		w.scheduleRelease()
+	}
+}
+
+// forget removes the held event with the given ID. Callers must hold
+// heldMut.
+func (w *WindowedSink) forget(id string) {
+	for i, e := range w.held {
+		if e.ID == id {
+			w.held = append(w.held[:i], w.held[i+1:]...)
+			return
+		}
+	}
+}
+
+// Done stops releasing held events, and closes the wrapped sink. Held
+// events are kept on disk for the next start.
+func (w *WindowedSink) Done() {
+	w.heldMut.Lock()
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.heldMut.Unlock()
+
+	w.releasing.Wait()
+	w.inner.Done()
+}
+
+// load reads the persisted held events, and whether they should be saved
+// again, sealed with the current key.
+func (w *WindowedSink) load() ([]event.Event, bool, error) {
+	if w.opts.Path == "" {
+		return nil, false, nil
+	}
+	b, err := os.ReadFile(w.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("schedule: %w", err)
+	}
+	plaintext, err := w.opts.Keyring.Open(b)
+	if err != nil {
+		return nil, false, fmt.Errorf("schedule: %s: %w", w.opts.Path, err)
+	}
+	var held []event.Event
+	if err := json.Unmarshal(plaintext, &held); err != nil {
+		return nil, false, fmt.Errorf("schedule: %s: %w", w.opts.Path, err)
+	}
+	return held, w.opts.Keyring.NeedsRekey(b), nil
+}
+
+// save persists held events. Callers must hold heldMut.
+func (w *WindowedSink) save() error {
+	if w.opts.Path == "" {
+		return nil
+	}
+	b, err := json.Marshal(w.held)
+	if err != nil {
+		return err
+	}
+	if b, err = w.opts.Keyring.Seal(b); err != nil {
+		return err
+	}
+	return atomicfile.Write(w.opts.Path, b)
+}
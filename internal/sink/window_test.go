@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/schedule"
+)
+
+// failingSink fails the events in fail, and delivers the rest.
+type failingSink struct {
+	memorySink
+	fail map[string]bool
+}
+
+func (s *failingSink) ProcessEvent(e event.Event) error {
+	if s.fail[e.ID] {
+		return errors.New("unavailable")
+	}
+	return s.memorySink.ProcessEvent(e)
+}
+
+// closedWindows returns windows which won't open for an hour.
+func closedWindows(t *testing.T) schedule.Windows {
+	t.Helper()
+	now := time.Now().UTC()
+	w, err := schedule.ParseWindow(now.Add(time.Hour).Format("15:04"), now.Add(2*time.Hour).Format("15:04"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schedule.Windows{Windows: []schedule.Window{w}, Location: time.UTC}
+}
+
+func TestWindowedSinkRelease(t *testing.T) {
+	events := []event.Event{
+		event.Sample(event.ObjectGrabbed),
+		event.Sample(event.ObjectDownloaded),
+		event.Sample(event.ObjectCompleted),
+	}
+	inner := &failingSink{fail: map[string]bool{events[1].ID: true}}
+	path := filepath.Join(t.TempDir(), "held.json")
+	w, err := NewWindowedSink(inner, WindowOpts{Windows: closedWindows(t), Action: OutOfWindowHold, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	per := 50 * time.Millisecond
+	p := NewSinkProcessor(w, 10)
+	p.limiter = newTokenBucket(RateLimitOpts{Events: 1, Per: per, Burst: 1})
+	p.Start(nil)
+	defer func() {
+		p.Done()
+		p.Wait()
+	}()
+
+	for _, e := range events {
+		p.enqueue(e)
+	}
+	eventually(t, "the events to be held", func() bool {
+		w.heldMut.Lock()
+		defer w.heldMut.Unlock()
+		return len(w.held) == len(events)
+	})
+
+	start := time.Now()
+	w.releaseHeld()
+	if elapsed := time.Since(start); elapsed < 2*per {
+		t.Errorf("released %d events in %s, want them rate limited", len(events), elapsed)
+	}
+	if delivered := inner.delivered(); len(delivered) != 2 {
+		t.Errorf("delivered %d events, want 2", len(delivered))
+	}
+	if stats := p.Stats(); stats.Held != 3 || stats.Delivered != 2 || stats.Failed != 1 {
+		t.Errorf("Held = %d, Delivered = %d, Failed = %d, want 3, 2 and 1", stats.Held, stats.Delivered, stats.Failed)
+	}
+	held, _, err := w.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 1 || held[0].ID != events[1].ID {
+		t.Errorf("still held %v, want only the failed event", held)
+	}
+}
+
+// downSink fails every delivery, counting them.
+type downSink struct {
+	attempts atomic.Int32
+}
+
+func (s *downSink) ProcessEvent(event.Event) error {
+	s.attempts.Add(1)
+	return errors.New("unavailable")
+}
+
+func (s *downSink) Done() {}
+
+func TestWindowedSinkRetryBackoff(t *testing.T) {
+	inner := &downSink{}
+	w, err := NewWindowedSink(inner, WindowOpts{Windows: closedWindows(t), Action: OutOfWindowHold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.retryMin, w.retryMax = 10*time.Millisecond, 40*time.Millisecond
+	p := NewSinkProcessor(w, 10)
+	p.Start(nil)
+	defer func() {
+		p.Done()
+		p.Wait()
+	}()
+
+	p.enqueue(event.Sample(event.ObjectGrabbed))
+	eventually(t, "the event to be held", func() bool {
+		w.heldMut.Lock()
+		defer w.heldMut.Unlock()
+		return len(w.held) == 1
+	})
+	// The window opens, with the sink down.
+	w.heldMut.Lock()
+	w.opts.Windows = schedule.Windows{}
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.scheduleRelease()
+	w.heldMut.Unlock()
+
+	// Retried after 0, 10, 20, 40, 40... ms, rather than continuously.
+	time.Sleep(300 * time.Millisecond)
+	if n := inner.attempts.Load(); n < 3 || n > 12 {
+		t.Errorf("delivery attempted %d times in 300ms, want a few, backing off", n)
+	}
+	w.heldMut.Lock()
+	defer w.heldMut.Unlock()
+	if len(w.held) != 1 {
+		t.Errorf("held %d events, want the undelivered event kept", len(w.held))
+	}
+	if d := w.backoff(); d != w.retryMax {
+		t.Errorf("backoff = %s, want it capped at %s", d, w.retryMax)
+	}
+}
@@ -0,0 +1,154 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rtrox/informer/internal/atomicfile"
+	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/redact"
+)
+
+// Headers never captured, along with any whose name looks secret.
+var captureOmitHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// captureName matches the names of capture files, so that rotation never
+// removes anything else in the capture directory.
+var captureName = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z-\d{6}\.json$`)
+
+// CaptureOpts configures capturing a source's raw requests.
+type CaptureOpts struct {
+	Dir      string // Where captures are written, one file per request.
+	MaxFiles int    // The oldest captures are removed beyond this many.
+}
+
+// Capture writes each request a source receives, along with the resulting
+// event or error, to a directory, for building fixtures and reproducing
+// bugs. Captures can be replayed with informer replay.
+type Capture struct {
+	opts  CaptureOpts
+	mut   sync.Mutex // serializes writes, and rotation
+	seq   uint64
+	files []string // Captures in Dir, oldest first.
+}
+
+// Captured is a single captured request.
+type Captured struct {
+	CapturedAt time.Time           `json:"captured_at"`
+	Source     string              `json:"source"`
+	Type       string              `json:"type"`
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Headers    map[string][]string `json:"headers"`
+	Body       json.RawMessage     `json:"body,omitempty"`      // The body, if JSON.
+	BodyText   string              `json:"body_text,omitempty"` // The body, if not.
+	Event      *event.Event        `json:"event,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+func NewCapture(opts CaptureOpts) (*Capture, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("capture: dir is required")
+	}
+	if opts.MaxFiles <= 0 {
+		return nil, fmt.Errorf("capture: max-files must be positive")
+	}
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	c := &Capture{opts: opts}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && captureName.MatchString(entry.Name()) {
+			c.files = append(c.files, entry.Name())
+		}
+	}
+	sort.Strings(c.files) // Named by time, so oldest first.
+	return c, nil
+}
+
+// Record captures the request r to the named source, whose body has
+// already been read, and its result, removing the oldest captures if there
+// are more than MaxFiles.
+func (c *Capture) Record(name string, typ string, r *http.Request, body []byte, e event.Event, err error) error {
+	captured := Captured{
+		CapturedAt: time.Now(),
+		Source:     name,
+		Type:       typ,
+		Method:     r.Method,
+		URL:        captureURL(r.URL),
+		Headers:    make(map[string][]string),
+	}
+	for key, values := range r.Header {
+		if captureOmitHeaders[key] || redact.IsSecretKey(key) {
+			continue
+		}
+		captured.Headers[key] = values
+	}
+	if json.Valid(body) {
+		captured.Body = body
+	} else {
+		captured.BodyText = string(body)
+	}
+	if err != nil {
+		captured.Error = err.Error()
+	} else {
+		captured.Event = &e
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(captured); err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.seq++
+	file := fmt.Sprintf("%s-%06d.json", captured.CapturedAt.UTC().Format("20060102T150405.000000000Z"), c.seq%1000000)
+	if err := atomicfile.Write(filepath.Join(c.opts.Dir, file), []byte(redact.String(buf.String()))); err != nil {
+		return err
+	}
+	c.files = append(c.files, file)
+	return c.rotate()
+}
+
+// rotate removes the oldest captures beyond MaxFiles. Only captures are
+// counted and removed, as Dir may be shared with other files. Callers must
+// hold mut.
+func (c *Capture) rotate() error {
+	for len(c.files) > c.opts.MaxFiles {
+		if err := os.Remove(filepath.Join(c.opts.Dir, c.files[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.files = c.files[1:]
+	}
+	return nil
+}
+
+// captureURL returns the request's path and query, with secret looking
+// query parameters redacted.
+func captureURL(u *url.URL) string {
+	query := u.Query()
+	for key := range query {
+		if redact.IsSecretKey(key) {
+			query[key] = []string{redact.Placeholder}
+		}
+	}
+	captured := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return captured.String()
+}
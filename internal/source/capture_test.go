@@ -0,0 +1,72 @@
+package source
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+func TestCaptureRotation(t *testing.T) {
+	dir := t.TempDir()
+	// A user's capture dir may hold other files, which must be kept.
+	others := []string{"fixture.json", "notes.txt", "00000000T000000.000000000Z-000001.json.bak"}
+	for _, name := range append(others, "20000101T000000.000000000Z-000001.json") {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := NewCapture(CaptureOpts{Dir: dir, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var captures []string
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("POST", "/webhook/radarr?apikey=secret", strings.NewReader(`{"eventType":"Test"}`))
+		r.Header.Set("Authorization", "Basic c2VjcmV0")
+		if err := c.Record("radarr", "radarr", r, []byte(`{"eventType":"Test"}`), event.Sample(event.TestEvent), nil); err != nil {
+			t.Fatal(err)
+		}
+		captures = append(captures, c.files[len(c.files)-1])
+	}
+	if err := c.Record("radarr", "radarr", httptest.NewRequest("POST", "/", nil), []byte("not json"), event.Event{}, errors.New("bad payload")); err != nil {
+		t.Fatal(err)
+	}
+	captures = append(captures, c.files[len(c.files)-1])
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	want := append(append([]string{}, others...), captures[2:]...)
+	sort.Strings(want)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, captures[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"c2VjcmV0", "apikey=secret"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("capture contains %q:\n%s", secret, b)
+		}
+	}
+	if b, err = os.ReadFile(filepath.Join(dir, captures[3])); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"body_text": "not json"`) || !strings.Contains(string(b), `"error": "bad payload"`) {
+		t.Errorf("capture of a failed request is missing its body or error:\n%s", b)
+	}
+}
@@ -1,28 +1,60 @@
 package source
 
 import (
+	"bytes"
+	"io"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 
-	"github.com/rs/zerolog/log"
 	"github.com/rtrox/informer/internal/event"
+	"github.com/rtrox/informer/internal/logging"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
+var sourcesLog = logging.For(logging.Sources)
+
+// Entry is a source, along with its registered type.
+type Entry struct {
+	Type    string
+	Source  Source
+	Capture *Capture // Captures the source's requests, if set.
+}
+
+// SourceStatus describes a configured source, and the webhooks it has
+// handled since it was registered.
+type SourceStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Health      string     `json:"health"` // ok, or failing if the most recent webhook failed.
+	Received    uint64     `json:"received"`
+	Failed      uint64     `json:"failed"`
+	LastEventAt *time.Time `json:"last_event_at"` // Nil if no event has been received.
+	LastErrorAt *time.Time `json:"last_error_at"` // Nil if no webhook has failed.
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type sourceState struct {
+	Entry
+	status    SourceStatus
+	statusMut sync.Mutex // protects status
+}
+
 type SourceManager struct {
-	sources   map[string]Source
+	sources   map[string]*sourceState
 	sourceMut sync.RWMutex
 }
 
 func NewSourceManager() *SourceManager {
 	return &SourceManager{
-		sources: make(map[string]Source),
+		sources: make(map[string]*sourceState),
 	}
 }
 
-func (s *SourceManager) UpdateSources(sources map[string]Source) {
+func (s *SourceManager) UpdateSources(sources map[string]Entry) {
 	s.sourceMut.Lock()
 	defer s.sourceMut.Unlock()
 
@@ -33,8 +65,56 @@ func (s *SourceManager) UpdateSources(sources map[string]Source) {
 		}
 	}
 
-	for name, source := range sources {
-		s.sources[name] = source
+	for name, entry := range sources {
+		s.sources[name] = &sourceState{
+			Entry:  entry,
+			status: SourceStatus{Name: name, Type: entry.Type},
+		}
+	}
+}
+
+// Sources returns the status of each source, sorted by name.
+func (s *SourceManager) Sources() []SourceStatus {
+	s.sourceMut.RLock()
+	defer s.sourceMut.RUnlock()
+
+	statuses := make([]SourceStatus, 0, len(s.sources))
+	for _, state := range s.sources {
+		state.statusMut.Lock()
+		status := state.status
+		state.statusMut.Unlock()
+		status.Health = "ok"
+		if status.LastErrorAt != nil && (status.LastEventAt == nil || status.LastErrorAt.After(*status.LastEventAt)) {
+			status.Health = "failing"
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *sourceState) record(err error) {
+	now := time.Now()
+	s.statusMut.Lock()
+	defer s.statusMut.Unlock()
+	if err != nil {
+		s.status.Failed++
+		s.status.LastErrorAt = &now
+		s.status.LastError = err.Error()
+		return
+	}
+	s.status.Received++
+	s.status.LastEventAt = &now
+}
+
+// capture records the request, if the source captures requests. Failures
+// are logged, rather than failing the request.
+func (s *sourceState) capture(name string, r *http.Request, body []byte, e event.Event, err error) {
+	if s.Capture == nil {
+		return
+	}
+	if err := s.Capture.Record(name, s.Type, r, body, e, err); err != nil {
+		sourcesLog.Warn().Err(err).Str("source", name).Msg("Failed to capture request")
 	}
 }
 
@@ -51,19 +131,43 @@ func (s *SourceManager) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 
 	sourceSlug := chi.URLParam(r, "source_slug")
 
-	if _, ok := s.sources[sourceSlug]; !ok {
+	state, ok := s.sources[sourceSlug]
+	if !ok {
 		render.Status(r, http.StatusNotFound)
 		render.JSON(w, r, map[string]interface{}{"code": http.StatusNotFound, "message": "source not found"})
 		return
 	}
 
-	e, err := s.sources[sourceSlug].HandleHTTP(w, r)
+	var body []byte
+	if state.Capture != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			sourcesLog.Warn().Err(err).Str("source", sourceSlug).Msg("Failed to read request body for capture")
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	e, err := state.Source.HandleHTTP(w, r)
+	state.record(err)
 	if err != nil {
-		log.Error().Err(err).Msg("Error while Handling Source")
+		state.capture(sourceSlug, r, body, e, err)
+		sourcesLog.Error().Err(err).Str("source", sourceSlug).Msg("Error while Handling Source")
 		render.Status(r, http.StatusInternalServerError) // TODO: better error handling
 		render.JSON(w, r, map[string]interface{}{"code": http.StatusInternalServerError, "message": err.Error()})
 		return
 	}
+	e.Stamp()
+	state.capture(sourceSlug, r, body, e, nil)
+	sourcesLog.Debug().
+		Str("source", sourceSlug).
+		Str("event_id", e.ID).
+		Str("correlation_id", e.CorrelationID).
+		Msg("Event Received.")
+
+	w.Header().Set("X-Informer-Event-Id", e.ID)
+	render.JSON(w, r, map[string]interface{}{"code": http.StatusOK, "message": "event accepted", "id": e.ID})
+
 	// Attach Event to request to be enqueued in middleware.
 	req := r.WithContext(event.WithEventContext(r.Context(), e))
 	*r = *req
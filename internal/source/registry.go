@@ -1,17 +1,38 @@
 package source
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
 
 var (
 	sourceRegistryInstance *sourceRegistry
 )
 
-type sourceConstructorFunc func(yaml.Node) Source
+// Opts are passed to every source constructor, along with its config.
+type Opts struct {
+	// Transport carries the source's own API calls, e.g. those enriching
+	// its events, in place of the default. Replay uses it to serve them
+	// from a fixture.
+	Transport http.RoundTripper
+	// Log is the sources component logger.
+	Log zerolog.Logger
+}
+
+type sourceConstructorFunc func(yaml.Node, Opts) (Source, error)
 type sourceValidatorFunc func(yaml.Node) error
 
 type SourceRegistryEntry struct {
 	Constructor sourceConstructorFunc
 	Validator   sourceValidatorFunc
+	// Config is the zero value of the type's config struct, against which
+	// config is checked for unknown keys, and from which its schema is
+	// generated. Nil accepts any config.
+	Config interface{}
 }
 
 type sourceRegistry struct {
@@ -35,11 +56,11 @@ func (s *sourceRegistry) registerSource(name string, entry SourceRegistryEntry)
 	s.entries[name] = entry
 }
 
-func (s *sourceRegistry) getSource(name string, opts yaml.Node) Source {
+func (s *sourceRegistry) getSource(name string, conf yaml.Node, opts Opts) (Source, error) {
 	if entry, ok := s.entries[name]; ok {
-		return entry.Constructor(opts)
+		return entry.Constructor(conf, opts)
 	}
-	panic("source not registered (name: " + name + ")")
+	return nil, fmt.Errorf("unknown source type %q", name)
 }
 
 func (s *sourceRegistry) validateConfig(name string, opts yaml.Node) error {
@@ -53,10 +74,33 @@ func RegisterSource(name string, entry SourceRegistryEntry) {
 	getRegistry().registerSource(name, entry)
 }
 
-func MakeSource(name string, opts yaml.Node) Source {
-	return getRegistry().getSource(name, opts)
+// MakeSource builds a source of the registered type. The config should already
+// have been validated.
+func MakeSource(name string, conf yaml.Node, opts Opts) (Source, error) {
+	return getRegistry().getSource(name, conf, opts)
 }
 
 func ValidateConfig(name string, opts yaml.Node) error {
 	return getRegistry().validateConfig(name, opts)
 }
+
+// Types returns the names of the registered source types, sorted.
+func Types() []string {
+	entries := getRegistry().entries
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func IsRegistered(name string) bool {
+	_, ok := getRegistry().entries[name]
+	return ok
+}
+
+// ConfigSample returns the registered type's Config, which may be nil.
+func ConfigSample(name string) interface{} {
+	return getRegistry().entries[name].Config
+}
@@ -0,0 +1,98 @@
+package source
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+var errBadValue = errors.New("bad value")
+
+type registryTestConfig struct {
+	Value string `yaml:"value"`
+}
+
+// registryTestSource keeps the opts it was built with.
+type registryTestSource struct {
+	opts Opts
+}
+
+func (s *registryTestSource) HandleHTTP(_ http.ResponseWriter, _ *http.Request) (event.Event, error) {
+	return event.Event{}, nil
+}
+
+func init() {
+	RegisterSource("registry-test", SourceRegistryEntry{
+		Constructor: func(conf yaml.Node, opts Opts) (Source, error) {
+			c := registryTestConfig{}
+			if err := conf.Decode(&c); err != nil {
+				return nil, err
+			}
+			if c.Value == "bad" {
+				return nil, errBadValue
+			}
+			return &registryTestSource{opts: opts}, nil
+		},
+		Validator: func(conf yaml.Node) error {
+			c := registryTestConfig{}
+			if err := conf.Decode(&c); err != nil {
+				return err
+			}
+			if c.Value == "bad" {
+				return errBadValue
+			}
+			return nil
+		},
+		Config: registryTestConfig{},
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		config  string
+		wantErr string
+	}{
+		{"valid", "registry-test", "value: good\n", ""},
+		{"bad value", "registry-test", "value: bad\n", errBadValue.Error()},
+		{"wrong kind", "registry-test", "value: [a, b]\n", "cannot unmarshal"},
+		{"unknown type", "registry-tset", "value: good\n", `unknown source type "registry-tset"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.config), &doc); err != nil {
+				t.Fatal(err)
+			}
+			conf := *doc.Content[0]
+			transport := &http.Transport{}
+			s, err := MakeSource(tt.typ, conf, Opts{Transport: transport})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("MakeSource() = %v, want a source", err)
+				}
+				if got := s.(*registryTestSource).opts.Transport; got != transport {
+					t.Errorf("built with transport %v, want the one passed", got)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("MakeSource() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if tt.typ != "registry-test" {
+				return
+			}
+			if err := ValidateConfig(tt.typ, conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateConfig() = %v, want it to agree with MakeSource", err)
+			}
+		})
+	}
+
+	if !IsRegistered("registry-test") || IsRegistered("registry-tset") {
+		t.Error("IsRegistered doesn't match the registered types")
+	}
+}
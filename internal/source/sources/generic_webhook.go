@@ -13,6 +13,7 @@ func init() {
 	source.RegisterSource("generic-webhook", source.SourceRegistryEntry{
 		Constructor: NewGenericWebhook,
 		Validator:   ValidateGenericWebhookConfig,
+		Config:      struct{}{},
 	})
 }
 
@@ -27,8 +28,8 @@ func (g *GenericWebhook) HandleHTTP(w http.ResponseWriter, r *http.Request) (eve
 	return e, nil
 }
 
-func NewGenericWebhook(_ yaml.Node) source.Source {
-	return &GenericWebhook{}
+func NewGenericWebhook(_ yaml.Node, _ source.Opts) (source.Source, error) {
+	return &GenericWebhook{}, nil
 }
 
 func ValidateGenericWebhookConfig(_ yaml.Node) error {
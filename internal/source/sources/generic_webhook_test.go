@@ -0,0 +1,35 @@
+package sources
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rtrox/informer/internal/source"
+)
+
+func TestGenericWebhook(t *testing.T) {
+	s, err := NewGenericWebhook(yamlNode(t, "{}\n"), source.Opts{})
+	checkErr(t, "NewGenericWebhook()", err, "")
+	checkErr(t, "ValidateGenericWebhookConfig()", ValidateGenericWebhookConfig(yamlNode(t, "")), "")
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"event", `{"title":"Backup finished","description":"42 GB in 3m"}`, ""},
+		{"not json", `title: Backup finished`, "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook/generic", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			e, err := s.HandleHTTP(httptest.NewRecorder(), r)
+			checkErr(t, "HandleHTTP()", err, tt.wantErr)
+			if tt.wantErr == "" && (e.Title != "Backup finished" || e.Description != "42 GB in 3m") {
+				t.Errorf("HandleHTTP() = %+v, want the posted event", e)
+			}
+		})
+	}
+}
@@ -2,16 +2,14 @@ package sources
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/go-chi/render"
-	"github.com/rs/zerolog/log"
 	"github.com/rtrox/informer/internal/event"
 	"github.com/rtrox/informer/internal/source"
-	"golift.io/starr"
 	"golift.io/starr/radarr"
 	"gopkg.in/yaml.v3"
 )
@@ -25,6 +23,7 @@ func init() {
 	source.RegisterSource("radarr", source.SourceRegistryEntry{
 		Constructor: NewRadarr,
 		Validator:   ValidateRadarrConfig,
+		Config:      RadarrConfig{},
 	})
 }
 
@@ -36,19 +35,20 @@ type Radarr struct {
 	client *radarr.Radarr
 }
 
-// TODO: Error propagation
-func NewRadarr(conf yaml.Node) source.Source {
+func NewRadarr(conf yaml.Node, opts source.Opts) (source.Source, error) {
 	c := RadarrConfig{}
 	if err := conf.Decode(&c); err != nil {
-		log.Error().Err(err).Msg("Failed to decode Radarr config.")
-		return &Radarr{}
+		return nil, fmt.Errorf("radarr: %w", err)
+	}
+	if err := validateStarrConfig(c.ApiKey, c.URL); err != nil {
+		return nil, fmt.Errorf("radarr: %w", err)
 	}
 
-	st := starr.New(c.ApiKey, c.URL, 0)
-	client := radarr.New(st)
-	return &Radarr{
-		client: client,
+	rd := &Radarr{}
+	if st := newStarrConfig(c.ApiKey, c.URL, opts.Transport); st != nil {
+		rd.client = radarr.New(st)
 	}
+	return rd, nil
 }
 
 func (rd *Radarr) HandleHTTP(w http.ResponseWriter, r *http.Request) (event.Event, error) {
@@ -69,23 +69,36 @@ func (rd *Radarr) HandleHTTP(w http.ResponseWriter, r *http.Request) (event.Even
 }
 
 func ValidateRadarrConfig(conf yaml.Node) error {
-	return conf.Decode(&RadarrConfig{})
+	c := RadarrConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return err
+	}
+	return validateStarrConfig(c.ApiKey, c.URL)
 }
 
 func commonRadarrFields(r RadarrEvent) event.Event {
-	return event.Event{
+	e := event.Event{
 		Source:          RadarrSource,
+		SourceInstance:  r.InstanceName,
+		CorrelationID:   r.DownloadID,
 		EventType:       r.EventType.Event(),
 		SourceEventType: r.EventType.String(),
 		SourceIconURL:   RadarrSourceIconURL,
 	}
+	if r.MovieFile != nil {
+		if t, err := time.Parse(time.RFC3339, r.MovieFile.DateAdded); err == nil {
+			e.OccurredAt = &t
+		}
+	}
+	return e
 }
 
 func (rd *Radarr) HandleHealthIssue(r RadarrEvent) (event.Event, error) {
 	e := commonRadarrFields(r)
 	e.Title = fmt.Sprintf("%s Health %s: %s", RadarrSource, r.Level, r.Type)
 	e.Description = r.Message
-	*e.LinkURL = r.WikiUrl
+	link := r.WikiUrl
+	e.LinkURL = &link
 	return e, nil
 }
 
@@ -103,22 +116,22 @@ func (rd *Radarr) HandleMovieEvent(r RadarrEvent) (event.Event, error) {
 	e.Title = fmt.Sprintf("[%s] %s", r.EventType.Description(), r.Movie.Title)
 	e.Description = fmt.Sprintf("Movie %s", r.EventType.Description())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	movie, err := rd.client.GetMovieByIDContext(ctx, r.Movie.ID)
-	if err != nil {
-		return event.Event{}, err
-	}
+	if rd.client != nil && r.Movie != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		movie, err := rd.client.GetMovieByIDContext(ctx, r.Movie.ID)
+		if err != nil {
+			return event.Event{}, err
+		}
 
-	if r.Movie != nil {
-		e.Metadata.Add("Overview", movie.Overview)
+		e.Metadata.AddString("overview", "Overview", movie.Overview, false)
 		if rating, ok := movie.Ratings["rottenTomatoes"]; ok {
-			e.Metadata.AddInline("Rating", fmt.Sprintf("🍅 %.1f", rating.Value))
+			e.Metadata.AddString("rating", "Rating", fmt.Sprintf("🍅 %.1f", rating.Value), true)
 		}
-		e.Metadata.AddInline("Release Date", r.Movie.ReleaseDate)
-		e.Metadata.AddInline("Runtime", fmt.Sprintf("%d minutes", movie.Runtime))
-		e.Metadata.Add("Rated", movie.Certification)
-		e.Metadata.Add("Genres", strings.Join(movie.Genres, ", "))
+		e.Metadata.AddString("release_date", "Release Date", r.Movie.ReleaseDate, true)
+		e.Metadata.AddDuration("runtime", "Runtime", time.Duration(movie.Runtime)*time.Minute, true)
+		e.Metadata.AddString("rated", "Rated", movie.Certification, false)
+		e.Metadata.AddList("genres", "Genres", movie.Genres, false)
 
 		for _, image := range movie.Images {
 			switch image.CoverType {
@@ -133,24 +146,24 @@ func (rd *Radarr) HandleMovieEvent(r RadarrEvent) (event.Event, error) {
 	}
 
 	if r.MovieFile != nil {
-		e.Metadata.AddInline("Quality", r.MovieFile.Quality)
-		e.Metadata.AddInline("Codecs", fmt.Sprintf("%s / %s", r.MovieFile.MediaInfo.VideoCodec, r.MovieFile.MediaInfo.AudioCodec))
-		e.Metadata.AddInline("File Size", fmt.Sprintf("%d", r.MovieFile.SizeBytes))
-		e.Metadata.Add("Language", strings.Join(r.MovieFile.MediaInfo.AudioLanguages, ", "))
-		e.Metadata.Add("Subtitles", strings.Join(r.MovieFile.MediaInfo.Subtitles, ", "))
-		e.Metadata.Add("Release Group", r.MovieFile.ReleaseGroup)
-		e.Metadata.Add("Release", r.MovieFile.SceneName)
+		e.Metadata.AddString("quality", "Quality", r.MovieFile.Quality, true)
+		e.Metadata.AddString("codecs", "Codecs", fmt.Sprintf("%s / %s", r.MovieFile.MediaInfo.VideoCodec, r.MovieFile.MediaInfo.AudioCodec), true)
+		e.Metadata.AddBytes("file_size", "File Size", r.MovieFile.SizeBytes, true)
+		e.Metadata.AddList("languages", "Language", r.MovieFile.MediaInfo.AudioLanguages, false)
+		e.Metadata.AddList("subtitles", "Subtitles", r.MovieFile.MediaInfo.Subtitles, false)
+		e.Metadata.AddString("release_group", "Release Group", r.MovieFile.ReleaseGroup, false)
+		e.Metadata.AddString("release", "Release", r.MovieFile.SceneName, false)
 
 	} else if r.Release != nil {
-		e.Metadata.AddInline("Quality", r.Release.Quality)
-		e.Metadata.AddInline("Formats", strings.Join(r.Release.CustomFormats, ", "))
-		e.Metadata.AddInline("File Size", fmt.Sprintf("%d", r.Release.SizeBytes))
-		e.Metadata.Add("Release Group", r.Release.ReleaseGroup)
-		e.Metadata.Add("Release", r.Release.ReleaseTitle)
+		e.Metadata.AddString("quality", "Quality", r.Release.Quality, true)
+		e.Metadata.AddList("custom_formats", "Formats", r.Release.CustomFormats, true)
+		e.Metadata.AddBytes("file_size", "File Size", r.Release.SizeBytes, true)
+		e.Metadata.AddString("release_group", "Release Group", r.Release.ReleaseGroup, false)
+		e.Metadata.AddString("release", "Release", r.Release.ReleaseTitle, false)
 	}
 
 	if r.IsUpgrade {
-		e.Metadata.Add("Quality Upgrade", "✅")
+		e.Metadata.AddString("quality_upgrade", "Quality Upgrade", "✅", false)
 	}
 	return e, nil
 }
@@ -210,7 +223,11 @@ func (e RadarrEventType) MarshalJSON() ([]byte, error) {
 }
 
 func (e *RadarrEventType) UnmarshalJSON(b []byte) error {
-	*e = RadarrEventType(b)
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*e = RadarrEventType(s)
 	return nil
 }
 
@@ -320,8 +337,10 @@ func (r RadarrHealthLevel) MarshalJSON() ([]byte, error) {
 }
 
 func (r *RadarrHealthLevel) UnmarshalJSON(b []byte) error {
-	s := string(b)
-	s = s[1 : len(s)-1]
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
 	*r = RadarrHealthLevel(s)
 	return nil
 }
@@ -350,7 +369,11 @@ func (r RadarrHealthCheckType) MarshalJSON() ([]byte, error) {
 }
 
 func (r *RadarrHealthCheckType) UnmarshalJSON(b []byte) error {
-	*r = RadarrHealthCheckType(b)
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*r = RadarrHealthCheckType(s)
 	return nil
 }
 
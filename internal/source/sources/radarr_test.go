@@ -0,0 +1,27 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/rtrox/informer/internal/source"
+)
+
+func TestNewRadarr(t *testing.T) {
+	for _, tt := range starrTests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr := ""
+			if tt.wantErr != "" {
+				wantErr = "radarr: " + tt.wantErr
+			}
+			conf := yamlNode(t, tt.config)
+			s, err := NewRadarr(conf, source.Opts{})
+			checkErr(t, "NewRadarr()", err, wantErr)
+			if err == nil && s == nil {
+				t.Fatal("NewRadarr() = nil, want a source")
+			}
+			if err := ValidateRadarrConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateRadarrConfig() = %v, want it to agree with NewRadarr", err)
+			}
+		})
+	}
+}
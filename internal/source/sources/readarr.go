@@ -1,11 +1,13 @@
 package sources
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
-	"github.com/rs/zerolog/log"
 	"github.com/rtrox/informer/internal/event"
-	"golift.io/starr"
+	"github.com/rtrox/informer/internal/redact"
+	"github.com/rtrox/informer/internal/source"
 	"golift.io/starr/readarr"
 	"gopkg.in/yaml.v3"
 )
@@ -21,18 +23,20 @@ type Readarr struct {
 	client *readarr.Readarr
 }
 
-func NewReadarr(conf yaml.Node) *Readarr {
+func NewReadarr(conf yaml.Node, opts source.Opts) (*Readarr, error) {
 	c := ReadarrConfig{}
 	if err := conf.Decode(&c); err != nil {
-		log.Error().Err(err).Msg("Failed to decode Readarr config.")
-		return &Readarr{}
+		return nil, fmt.Errorf("readarr: %w", err)
 	}
-	log.Info().Interface("config", c).Msg("Loaded Readarr config.")
-	st := starr.New(c.ApiKey, c.URL, 0)
-	client := readarr.New(st)
-	return &Readarr{
-		client: client,
+	if err := validateStarrConfig(c.ApiKey, c.URL); err != nil {
+		return nil, fmt.Errorf("readarr: %w", err)
 	}
+	opts.Log.Debug().Interface("config", redact.Value(c)).Msg("Loaded Readarr config.")
+	r := &Readarr{}
+	if st := newStarrConfig(c.ApiKey, c.URL, opts.Transport); st != nil {
+		r.client = readarr.New(st)
+	}
+	return r, nil
 }
 
 func (r *Readarr) HandleHTTP(w http.ResponseWriter, req *http.Request) (event.Event, error) {
@@ -90,7 +94,11 @@ func (e ReadarrEventType) MarshalJSON() ([]byte, error) {
 }
 
 func (e *ReadarrEventType) UnmarshalJSON(b []byte) error {
-	*e = ReadarrEventType(b)
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*e = ReadarrEventType(s)
 	return nil
 }
 
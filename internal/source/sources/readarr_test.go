@@ -0,0 +1,23 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/rtrox/informer/internal/source"
+)
+
+func TestNewReadarr(t *testing.T) {
+	for _, tt := range starrTests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr := ""
+			if tt.wantErr != "" {
+				wantErr = "readarr: " + tt.wantErr
+			}
+			r, err := NewReadarr(yamlNode(t, tt.config), source.Opts{})
+			checkErr(t, "NewReadarr()", err, wantErr)
+			if err == nil && r == nil {
+				t.Fatal("NewReadarr() = nil, want a source")
+			}
+		})
+	}
+}
@@ -8,12 +8,10 @@ import (
 	"time"
 
 	"github.com/go-chi/render"
-	"github.com/rs/zerolog/log"
 	"github.com/rtrox/informer/internal/event"
 	"github.com/rtrox/informer/internal/source"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
-	"golift.io/starr"
 	"golift.io/starr/sonarr"
 	"gopkg.in/yaml.v3"
 )
@@ -25,6 +23,7 @@ func init() {
 	source.RegisterSource("sonarr", source.SourceRegistryEntry{
 		Constructor: NewSonarrWebhook,
 		Validator:   ValidateSonarrConfig,
+		Config:      SonarrConfig{},
 	})
 }
 
@@ -37,16 +36,19 @@ type Sonarr struct {
 	client *sonarr.Sonarr
 }
 
-func NewSonarrWebhook(conf yaml.Node) source.Source {
+func NewSonarrWebhook(conf yaml.Node, opts source.Opts) (source.Source, error) {
 	c := SonarrConfig{}
 	if err := conf.Decode(&c); err != nil {
-		log.Error().Err(err).Msg("Failed to decode Sonarr config")
+		return nil, fmt.Errorf("sonarr: %w", err)
 	}
-	st := starr.New(c.ApiKey, c.URL, 0)
-	client := sonarr.New(st)
-	return &Sonarr{
-		client: client,
+	if err := validateStarrConfig(c.ApiKey, c.URL); err != nil {
+		return nil, fmt.Errorf("sonarr: %w", err)
 	}
+	s := &Sonarr{}
+	if st := newStarrConfig(c.ApiKey, c.URL, opts.Transport); st != nil {
+		s.client = sonarr.New(st)
+	}
+	return s, nil
 }
 
 func (s *Sonarr) HandleHTTP(w http.ResponseWriter, r *http.Request) (event.Event, error) {
@@ -70,24 +72,37 @@ func (s *Sonarr) HandleHTTP(w http.ResponseWriter, r *http.Request) (event.Event
 	}
 }
 
-func ValidateSonarrConfig(_ yaml.Node) error {
-	return nil
+func ValidateSonarrConfig(conf yaml.Node) error {
+	c := SonarrConfig{}
+	if err := conf.Decode(&c); err != nil {
+		return err
+	}
+	return validateStarrConfig(c.ApiKey, c.URL)
 }
 
 func commonSonarrFields(se SonarrEvent) event.Event {
-	return event.Event{
+	e := event.Event{
 		Source:          SonarrSource,
+		SourceInstance:  se.InstanceName,
+		CorrelationID:   se.DownloadID,
 		EventType:       se.EventType.Event(),
 		SourceEventType: se.EventType.String(),
 		SourceIconURL:   SonarrIconURL,
 	}
+	if se.EpisodeFile != nil {
+		if t, err := time.Parse(time.RFC3339, se.EpisodeFile.DateAdded); err == nil {
+			e.OccurredAt = &t
+		}
+	}
+	return e
 }
 
 func (s *Sonarr) HandleHealthIssue(se SonarrEvent) (event.Event, error) {
 	e := commonSonarrFields(se)
 	e.Title = fmt.Sprintf("%s Health %s: %s", SonarrSource, se.Level, se.Type)
 	e.Description = se.Message
-	*e.LinkURL = se.WikiURL
+	link := se.WikiURL
+	e.LinkURL = &link
 	return e, nil
 }
 
@@ -104,6 +119,9 @@ func (s *Sonarr) HandleSeriesEvent(se SonarrEvent) (event.Event, error) {
 	e := commonSonarrFields(se)
 	e.Title = fmt.Sprintf("%s: %s (%d)", se.EventType.Description(), se.Series.Title, se.Series.Year)
 	e.Description = se.Message
+	if s.client == nil {
+		return e, nil
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -113,12 +131,12 @@ func (s *Sonarr) HandleSeriesEvent(se SonarrEvent) (event.Event, error) {
 		return event.Event{}, err
 	}
 
-	e.Metadata.Add("Overview", series.Overview)
-	e.Metadata.AddInline("Network", series.Network)
-	e.Metadata.AddInline("AirTime", series.AirTime)
-	e.Metadata.AddInline("Status", cases.Title(language.English).String(series.Status))
-	e.Metadata.Add("Rated", series.Certification)
-	e.Metadata.Add("Genres", strings.Join(series.Genres, ", "))
+	e.Metadata.AddString("overview", "Overview", series.Overview, false)
+	e.Metadata.AddString("network", "Network", series.Network, true)
+	e.Metadata.AddString("air_time", "AirTime", series.AirTime, true)
+	e.Metadata.AddString("status", "Status", cases.Title(language.English).String(series.Status), true)
+	e.Metadata.AddString("rated", "Rated", series.Certification, false)
+	e.Metadata.AddList("genres", "Genres", series.Genres, false)
 
 	for _, image := range series.Images {
 		if image.CoverType == "poster" {
@@ -149,31 +167,38 @@ func (s *Sonarr) HandleEpisodeEvent(se SonarrEvent) (event.Event, error) {
 	if len(se.Episodes) > 1 {
 		episodeLabel = "Episodes"
 	}
-	e.Metadata.Add(episodeLabel, strings.Join(episodeList, "\n"))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	e.Metadata.AddField(event.MetadataField{
+		Key:   "episodes",
+		Name:  episodeLabel,
+		Value: strings.Join(episodeList, "\n"),
+		Type:  event.MetadataTypeList,
+		Raw:   episodeList,
+	})
+	if s.client != nil && len(se.Episodes) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
 
-	episode, err := s.client.GetEpisodeByIDContext(ctx, se.Episodes[0].ID)
-	if err != nil {
-		return event.Event{}, err
-	}
-	if episode != nil {
-		e.Metadata.Add("Overview", episode.Overview)
-		e.Metadata.AddInline("Network", episode.Series.Network)
-		e.Metadata.AddInline("Air Date", episode.AirDate)
-		e.Metadata.Add("Rated", episode.Series.Certification)
-
-		for _, image := range episode.Series.Images {
-			if image.CoverType == "poster" {
-				img := image.RemoteURL
-				e.ThumbnailURL = &img
-			}
+		episode, err := s.client.GetEpisodeByIDContext(ctx, se.Episodes[0].ID)
+		if err != nil {
+			return event.Event{}, err
 		}
-		for _, image := range episode.Images {
-			if image.CoverType == "screenshot" {
-				img := image.RemoteURL
-				e.ImageURL = &img
+		if episode != nil {
+			e.Metadata.AddString("overview", "Overview", episode.Overview, false)
+			e.Metadata.AddString("network", "Network", episode.Series.Network, true)
+			e.Metadata.AddString("air_date", "Air Date", episode.AirDate, true)
+			e.Metadata.AddString("rated", "Rated", episode.Series.Certification, false)
+
+			for _, image := range episode.Series.Images {
+				if image.CoverType == "poster" {
+					img := image.RemoteURL
+					e.ThumbnailURL = &img
+				}
+			}
+			for _, image := range episode.Images {
+				if image.CoverType == "screenshot" {
+					img := image.RemoteURL
+					e.ImageURL = &img
+				}
 			}
 		}
 	}
@@ -187,14 +212,13 @@ func (s *Sonarr) HandleEpisodeEvent(se SonarrEvent) (event.Event, error) {
 	}
 
 	if se.EpisodeFile != nil {
-		e.Metadata.AddInline("Quality", episodeFile.Quality)
-		e.Metadata.AddInline("Codecs", fmt.Sprintf("%s / %s", episodeFile.MediaInfo.VideoCodec, episodeFile.MediaInfo.AudioCodec))
-		e.Metadata.Add("File Size", fmt.Sprintf("%d", episodeFile.Size))
-		e.Metadata.Add("Release Group", episodeFile.ReleaseGroup)
-		e.Metadata.Add("Language", strings.Join(episodeFile.MediaInfo.AudioLanguages, ", "))
-		e.Metadata.Add("Subtitles", strings.Join(episodeFile.MediaInfo.Subtitles, ", "))
-		e.Metadata.Add("Release Group", episodeFile.ReleaseGroup)
-		e.Metadata.Add("Release", episodeFile.SceneName)
+		e.Metadata.AddString("quality", "Quality", episodeFile.Quality, true)
+		e.Metadata.AddString("codecs", "Codecs", fmt.Sprintf("%s / %s", episodeFile.MediaInfo.VideoCodec, episodeFile.MediaInfo.AudioCodec), true)
+		e.Metadata.AddBytes("file_size", "File Size", episodeFile.Size, false)
+		e.Metadata.AddList("languages", "Language", episodeFile.MediaInfo.AudioLanguages, false)
+		e.Metadata.AddList("subtitles", "Subtitles", episodeFile.MediaInfo.Subtitles, false)
+		e.Metadata.AddString("release_group", "Release Group", episodeFile.ReleaseGroup, false)
+		e.Metadata.AddString("release", "Release", episodeFile.SceneName, false)
 	}
 	return e, nil
 }
@@ -0,0 +1,27 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/rtrox/informer/internal/source"
+)
+
+func TestNewSonarrWebhook(t *testing.T) {
+	for _, tt := range starrTests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr := ""
+			if tt.wantErr != "" {
+				wantErr = "sonarr: " + tt.wantErr
+			}
+			conf := yamlNode(t, tt.config)
+			s, err := NewSonarrWebhook(conf, source.Opts{})
+			checkErr(t, "NewSonarrWebhook()", err, wantErr)
+			if err == nil && s == nil {
+				t.Fatal("NewSonarrWebhook() = nil, want a source")
+			}
+			if err := ValidateSonarrConfig(conf); (err == nil) != (tt.wantErr == "") {
+				t.Errorf("ValidateSonarrConfig() = %v, want it to agree with NewSonarrWebhook", err)
+			}
+		})
+	}
+}
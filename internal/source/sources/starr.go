@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golift.io/starr"
+)
+
+// newStarrConfig returns the API connection of a *arr source, or nil if no
+// url is configured, in which case events aren't enriched from the API.
+// transport replaces the default, if set.
+func newStarrConfig(apiKey string, rawURL string, transport http.RoundTripper) *starr.Config {
+	if rawURL == "" {
+		return nil
+	}
+	st := starr.New(apiKey, rawURL, 0)
+	if transport != nil {
+		st.Client.Transport = transport
+	}
+	return st
+}
+
+// validateStarrConfig checks the API connection of a *arr source, which is
+// optional, but needs both an absolute http(s) URL and an API key if used.
+func validateStarrConfig(apiKey string, rawURL string) error {
+	if rawURL == "" {
+		if apiKey != "" {
+			return fmt.Errorf("url is required with api-key")
+		}
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url: expected an http or https URL, e.g. http://sonarr:8989, got %q", rawURL)
+	}
+	if apiKey == "" {
+		return fmt.Errorf("api-key is required with url")
+	}
+	return nil
+}
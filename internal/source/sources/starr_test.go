@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlNode parses conf, returning its top level node, as constructors are
+// passed.
+func yamlNode(t *testing.T, conf string) yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(conf), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Content) == 0 {
+		return yaml.Node{}
+	}
+	return *doc.Content[0]
+}
+
+// checkErr checks that err contains wantErr, or is nil if wantErr is empty.
+func checkErr(t *testing.T, what string, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("%s = %v, want no error", what, err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("%s = %v, want an error containing %q", what, err, wantErr)
+	}
+}
+
+// starrTests are the configs shared by the *arr sources, and the error
+// each should be rejected with, after the source's name.
+var starrTests = []struct {
+	name    string
+	config  string
+	wantErr string
+}{
+	{"without api", "", ""},
+	{"with api", "url: http://arr:8989\napi-key: abcdef123456\n", ""},
+	{"https api", "url: https://arr.example/base\napi-key: abcdef123456\n", ""},
+	{"missing api-key", "url: http://arr:8989\n", "api-key is required with url"},
+	{"missing url", "api-key: abcdef123456\n", "url is required with api-key"},
+	{"no scheme", "url: arr:8989\napi-key: abcdef123456\n", "url: expected an http or https URL"},
+	{"bad scheme", "url: ftp://arr\napi-key: abcdef123456\n", "url: expected an http or https URL"},
+	{"unparseable url", "url: 'http://arr:port'\napi-key: abcdef123456\n", "url: parse"},
+	{"wrong kind", "url: [a]\n", "yaml: unmarshal errors"},
+}
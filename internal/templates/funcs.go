@@ -0,0 +1,153 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// Funcs returns the helper functions available to every sink template.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"humanizeBytes":    HumanizeBytes,
+		"humanizeDuration": HumanizeDuration,
+		"truncate":         Truncate,
+		"escapeMarkdown":   EscapeMarkdown,
+		"join":             Join,
+		"formatDate":       FormatDate,
+		"upper":            strings.ToUpper,
+		"lower":            strings.ToLower,
+		"trim":             strings.TrimSpace,
+		"default":          Default,
+	}
+}
+
+// HumanizeBytes renders a byte count using binary units, e.g. 1.4 GiB.
+// Accepts any integer type, or a string containing an integer.
+func HumanizeBytes(v interface{}) (string, error) {
+	var n int64
+	switch b := v.(type) {
+	case int:
+		n = int64(b)
+	case int32:
+		n = int64(b)
+	case int64:
+		n = b
+	case uint64:
+		n = int64(b)
+	case float64:
+		n = int64(b)
+	case string:
+		if _, err := fmt.Sscan(b, &n); err != nil {
+			return "", fmt.Errorf("humanizeBytes: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("humanizeBytes: unsupported type %T", v)
+	}
+
+	return event.FormatBytes(n), nil
+}
+
+// HumanizeDuration renders a time.Duration, or a duration string such as
+// 90m, at minute precision, e.g. 1h 30m.
+func HumanizeDuration(v interface{}) (string, error) {
+	switch d := v.(type) {
+	case time.Duration:
+		return event.FormatDuration(d), nil
+	case string:
+		parsed, err := time.ParseDuration(d)
+		if err != nil {
+			return "", fmt.Errorf("humanizeDuration: %w", err)
+		}
+		return event.FormatDuration(parsed), nil
+	default:
+		return "", fmt.Errorf("humanizeDuration: unsupported type %T", v)
+	}
+}
+
+// Truncate shortens s to at most length runes, ending with an ellipsis
+// when truncated.
+func Truncate(length int, s string) string {
+	if length < 0 {
+		length = 0
+	}
+	r := []rune(s)
+	if len(r) <= length {
+		return s
+	}
+	if length <= 1 {
+		return string(r[:length])
+	}
+	return string(r[:length-1]) + "…"
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+	">", `\>`,
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// EscapeMarkdown escapes characters which Discord flavoured markdown
+// would otherwise interpret.
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// Join concatenates a list of strings with sep. Pipeline friendly:
+// {{ .List | join ", " }}.
+func Join(sep string, v interface{}) (string, error) {
+	switch l := v.(type) {
+	case []string:
+		return strings.Join(l, sep), nil
+	case []interface{}:
+		parts := make([]string, 0, len(l))
+		for _, p := range l {
+			parts = append(parts, fmt.Sprint(p))
+		}
+		return strings.Join(parts, sep), nil
+	default:
+		return "", fmt.Errorf("join: unsupported type %T", v)
+	}
+}
+
+// FormatDate formats a time.Time, or an RFC 3339 string, with a Go
+// reference layout.
+func FormatDate(layout string, v interface{}) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+		return t.Format(layout), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return "", fmt.Errorf("formatDate: %w", err)
+		}
+		return parsed.Format(layout), nil
+	default:
+		return "", fmt.Errorf("formatDate: unsupported type %T", v)
+	}
+}
+
+// Default returns def when v is empty.
+func Default(def string, v interface{}) string {
+	if v == nil {
+		return def
+	}
+	if s := fmt.Sprint(v); s != "" {
+		return s
+	}
+	return def
+}
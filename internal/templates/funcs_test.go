@@ -0,0 +1,27 @@
+package templates
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		s      string
+		want   string
+	}{
+		{"shorter", 10, "hello", "hello"},
+		{"exact", 5, "hello", "hello"},
+		{"longer", 4, "hello", "hel…"},
+		{"runes", 3, "héllo", "hé…"},
+		{"one", 1, "hello", "h"},
+		{"zero", 0, "hello", ""},
+		{"negative", -3, "hello", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.length, tt.s); got != tt.want {
+				t.Errorf("Truncate(%d, %q) = %q, want %q", tt.length, tt.s, got, tt.want)
+			}
+		})
+	}
+}
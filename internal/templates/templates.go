@@ -0,0 +1,157 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/rtrox/informer/internal/event"
+)
+
+// DefaultKey is the Config key whose templates apply to any EventType
+// without templates of its own.
+const DefaultKey = "default"
+
+// MessageTemplate holds the templates for each part of a message. Each part
+// may be given inline, or loaded from a file with the matching -file key.
+type MessageTemplate struct {
+	Title      string `yaml:"title"`
+	TitleFile  string `yaml:"title-file"`
+	Body       string `yaml:"body"`
+	BodyFile   string `yaml:"body-file"`
+	Footer     string `yaml:"footer"`
+	FooterFile string `yaml:"footer-file"`
+}
+
+// Config maps EventType names (as produced by EventType.String()), or
+// DefaultKey, to their templates. Sinks embed it in their own config as
+// `templates`.
+type Config map[string]MessageTemplate
+
+// Message is a rendered message. Parts without a template are left as
+// provided by the sink.
+type Message struct {
+	Title  string
+	Body   string
+	Footer string
+}
+
+type parsedTemplate struct {
+	title  *template.Template
+	body   *template.Template
+	footer *template.Template
+}
+
+// Set is a parsed Config, ready to render events. A nil Set renders
+// nothing, leaving the sink's own layout in place.
+type Set struct {
+	byType   map[event.EventType]parsedTemplate
+	fallback parsedTemplate
+}
+
+// Parse loads any template files, and parses every template. Sinks should
+// call it from their validator so that syntax errors fail at startup.
+func (c Config) Parse() (*Set, error) {
+	s := &Set{byType: make(map[event.EventType]parsedTemplate)}
+	for key, mt := range c {
+		p, err := mt.parse(key)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(key, DefaultKey) {
+			s.fallback = p
+			continue
+		}
+		t, err := event.ParseEventType(key)
+		if err != nil {
+			return nil, fmt.Errorf("templates: %w", err)
+		}
+		s.byType[t] = p
+	}
+	return s, nil
+}
+
+func (m MessageTemplate) parse(key string) (parsedTemplate, error) {
+	var (
+		p   parsedTemplate
+		err error
+	)
+	if p.title, err = parsePart(key+".title", m.Title, m.TitleFile); err != nil {
+		return p, err
+	}
+	if p.body, err = parsePart(key+".body", m.Body, m.BodyFile); err != nil {
+		return p, err
+	}
+	if p.footer, err = parsePart(key+".footer", m.Footer, m.FooterFile); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func parsePart(name string, text string, file string) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, fmt.Errorf("templates: %s: only one of inline template or file may be set", name)
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("templates: %s: %w", name, err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	t, err := New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+	return t, nil
+}
+
+// New returns an empty template with the shared helper functions attached.
+func New(name string) *template.Template {
+	return template.New(name).Funcs(Funcs()).Option("missingkey=zero")
+}
+
+// Render renders each templated part of the message for the event. Parts
+// without a template for the event's type, or a default, keep their value
+// from m.
+func (s *Set) Render(e event.Event, m Message) (Message, error) {
+	if s == nil {
+		return m, nil
+	}
+	p := s.byType[e.EventType]
+	var err error
+	if m.Title, err = renderPart(first(p.title, s.fallback.title), e, m.Title); err != nil {
+		return m, err
+	}
+	if m.Body, err = renderPart(first(p.body, s.fallback.body), e, m.Body); err != nil {
+		return m, err
+	}
+	if m.Footer, err = renderPart(first(p.footer, s.fallback.footer), e, m.Footer); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func first(t ...*template.Template) *template.Template {
+	for _, tmpl := range t {
+		if tmpl != nil {
+			return tmpl
+		}
+	}
+	return nil
+}
+
+func renderPart(t *template.Template, e event.Event, fallback string) (string, error) {
+	if t == nil {
+		return fallback, nil
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, e); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
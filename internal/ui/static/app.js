@@ -0,0 +1,302 @@
+"use strict";
+
+const api = "../api/v1";
+const pages = ["status", "sources", "sinks", "events", "access"];
+let types = { sources: [], sinks: [], event_types: [] };
+let identity = null;
+
+async function request(method, path, body, contentType) {
+  const headers = {};
+  if (identity && identity.csrf_token) {
+    headers["X-CSRF-Token"] = identity.csrf_token;
+  }
+  if (contentType) {
+    headers["Content-Type"] = contentType;
+  }
+  const resp = await fetch(api + path, { method, headers, body });
+  if (resp.status === 401) {
+    location.href = "login.html";
+    throw new Error("unauthorized");
+  }
+  const text = await resp.text();
+  const isJSON = (resp.headers.get("Content-Type") || "").includes("json");
+  const data = isJSON && text ? JSON.parse(text) : text;
+  if (!resp.ok) {
+    throw new Error(isJSON ? data.message : text);
+  }
+  return data;
+}
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k.startsWith("on")) {
+      e.addEventListener(k.slice(2), v);
+    } else {
+      e.setAttribute(k, v);
+    }
+  }
+  for (const c of children) {
+    e.append(c instanceof Node ? c : document.createTextNode(c ?? ""));
+  }
+  return e;
+}
+
+function when(t) {
+  return t ? new Date(t).toLocaleString() : "";
+}
+
+async function show() {
+  const page = pages.includes(location.hash.slice(1)) ? location.hash.slice(1) : "status";
+  for (const p of pages) {
+    document.getElementById(p).hidden = p !== page;
+  }
+  try {
+    if (!identity) {
+      identity = await request("GET", "/auth/session");
+      document.getElementById("whoami").textContent = identity.username;
+      document.getElementById("logout").hidden = identity.method !== "session";
+    }
+    if (!types.sinks.length) {
+      types = await request("GET", "/config/types");
+      const select = document.querySelector("#event-filter [name=type]");
+      for (const t of types.event_types) {
+        select.append(el("option", { value: t }, t));
+      }
+    }
+    await {
+      status: loadStatus,
+      sources: loadConfigList,
+      sinks: loadConfigList,
+      events: loadEvents,
+      access: loadAccess,
+    }[page](page);
+  } catch (err) {
+    console.error(err);
+  }
+}
+
+async function loadStatus() {
+  const [health, sinks, sources] = await Promise.all([
+    request("GET", "/health"),
+    request("GET", "/sinks"),
+    request("GET", "/sources"),
+  ]);
+  const overall = document.getElementById("overall");
+  overall.textContent = health.status;
+  overall.className = "health-" + health.status;
+
+  const action = (name, verb) => el("button", {
+    onclick: async () => {
+      const result = await request("POST", `/sinks/${encodeURIComponent(name)}/${verb}`);
+      if (verb === "drain") {
+        alert(`Discarded ${result.drained} queued events.`);
+      }
+      loadStatus();
+    },
+  }, verb);
+
+  document.getElementById("sink-status").replaceChildren(...sinks.sinks.map((s) => el("tr", {},
+    el("td", {}, s.name),
+    el("td", {}, s.type),
+    el("td", { class: "health-" + s.health }, s.health),
+    el("td", {}, `${s.stats.queued} / ${s.stats.queue_length}`),
+    el("td", {}, String(s.stats.delivered)),
+    el("td", {}, String(s.stats.failed)),
+    el("td", {}, String(s.stats.dropped)),
+    el("td", { class: "error" }, s.stats.last_error || ""),
+    el("td", {}, action(s.name, s.stats.paused ? "resume" : "pause"), action(s.name, "drain"), action(s.name, "test")),
+  )));
+  document.getElementById("source-status").replaceChildren(...sources.sources.map((s) => el("tr", {},
+    el("td", {}, s.name),
+    el("td", {}, s.type),
+    el("td", { class: "health-" + s.health }, s.health),
+    el("td", {}, String(s.received)),
+    el("td", {}, String(s.failed)),
+    el("td", {}, when(s.last_event_at)),
+    el("td", { class: "error" }, s.last_error || ""),
+  )));
+}
+
+async function loadConfigList(kind) {
+  const data = await request("GET", `/config/${kind}`);
+  const list = document.getElementById(kind === "sinks" ? "sink-list" : "source-list");
+  list.replaceChildren(...(data[kind] || []).map((c) => el("li", {},
+    el("span", {}, c.name, " ", el("span", { class: "type" }, c.type)),
+    el("button", { onclick: () => edit(kind, c.name) }, "Edit"),
+    el("button", {
+      onclick: async () => {
+        if (confirm(`Delete ${c.name}?`)) {
+          await request("DELETE", `/config/${kind}/${encodeURIComponent(c.name)}`);
+          loadConfigList(kind);
+        }
+      },
+    }, "Delete"),
+  )));
+}
+
+// stripKeys removes top-level keys, and their nested blocks, from YAML text.
+function stripKeys(yaml, keys) {
+  const out = [];
+  let skipping = false;
+  for (const line of yaml.split("\n")) {
+    if (/^\S/.test(line)) {
+      skipping = keys.some((k) => line.startsWith(k + ":"));
+    }
+    if (!skipping) {
+      out.push(line);
+    }
+  }
+  return out.join("\n").trim();
+}
+
+async function edit(kind, name) {
+  const dialog = document.getElementById("editor");
+  const form = document.getElementById("editor-form");
+  form.reset();
+  document.getElementById("editor-error").textContent = "";
+  document.getElementById("editor-title").textContent = (name ? "Edit " : "Add ") + kind.slice(0, -1);
+  document.getElementById("route-fields").hidden = kind !== "sinks";
+
+  form.type.replaceChildren(...types[kind].map((t) => el("option", { value: t }, t)));
+  form["event-types"].replaceChildren(...types.event_types.map((t) => el("option", { value: t }, t)));
+  form.elements.name.readOnly = !!name;
+
+  if (name) {
+    const path = `/config/${kind}/${encodeURIComponent(name)}`;
+    const [entry, yaml] = await Promise.all([request("GET", path), request("GET", path + "?format=yaml")]);
+    form.elements.name.value = entry.name;
+    form.type.value = entry.type;
+    const filter = entry.filter || {};
+    for (const option of form["event-types"].options) {
+      option.selected = (filter["event-types"] || []).includes(option.value);
+    }
+    form["route-sources"].value = (filter.sources || []).join(", ");
+    form.yaml.value = stripKeys(yaml, ["name", "type", "filter"]);
+  } else {
+    form.yaml.value = "config: {}";
+  }
+
+  form.onsubmit = async (ev) => {
+    if (ev.submitter && ev.submitter.value === "cancel") {
+      return;
+    }
+    ev.preventDefault();
+    let body = `type: ${JSON.stringify(form.type.value)}\n`;
+    if (kind === "sinks") {
+      const filter = {
+        "event-types": [...form["event-types"].selectedOptions].map((o) => o.value),
+        sources: form["route-sources"].value.split(",").map((s) => s.trim()).filter((s) => s),
+      };
+      body += `filter: ${JSON.stringify(filter)}\n`;
+    }
+    body += form.yaml.value;
+    try {
+      await request("PUT", `/config/${kind}/${encodeURIComponent(form.elements.name.value)}`, body, "application/yaml");
+      dialog.close();
+      loadConfigList(kind);
+    } catch (err) {
+      document.getElementById("editor-error").textContent = err.message;
+    }
+  };
+  dialog.showModal();
+}
+
+async function loadEvents() {
+  const params = new URLSearchParams();
+  for (const [k, v] of new FormData(document.getElementById("event-filter"))) {
+    if (v) {
+      params.set(k, v);
+    }
+  }
+  let data;
+  try {
+    data = await request("GET", "/events/?" + params);
+  } catch (err) {
+    document.getElementById("history-disabled").hidden = false;
+    return;
+  }
+  document.getElementById("history-disabled").hidden = true;
+  document.getElementById("event-list").replaceChildren(...data.events.map((r) => el("tr", {},
+    el("td", {}, when(r.event.received_at)),
+    el("td", {}, r.event.source),
+    el("td", {}, types.event_types[r.event.type - 1] || "Unknown"),
+    el("td", {}, r.event.title),
+    el("td", {}, ...r.deliveries.map((d) => el("div", { class: "status-" + d.status, title: d.error || "" },
+      `${d.sink || "broker"}: ${d.status}`))),
+  )));
+}
+
+async function loadAccess() {
+  const [users, tokens] = await Promise.all([request("GET", "/auth/users"), request("GET", "/auth/tokens")]);
+  const remove = (path, label, reload) => el("button", {
+    onclick: async () => {
+      if (!confirm(`Delete ${label}?`)) {
+        return;
+      }
+      try {
+        await request("DELETE", path);
+        reload();
+      } catch (err) {
+        document.getElementById("access-error").textContent = err.message;
+      }
+    },
+  }, "Delete");
+
+  document.getElementById("user-list").replaceChildren(...users.users.map((u) => el("li", {},
+    el("span", {}, u.username, " ", el("span", { class: "type" }, u.configured ? "config file" : "")),
+    u.configured ? "" : el("button", {
+      onclick: async () => {
+        const password = prompt(`New password for ${u.username}`);
+        if (password) {
+          try {
+            await request("PUT", `/auth/users/${encodeURIComponent(u.username)}/password`,
+              JSON.stringify({ password }), "application/json");
+          } catch (err) {
+            document.getElementById("access-error").textContent = err.message;
+          }
+        }
+      },
+    }, "Change password"),
+    u.configured ? "" : remove(`/auth/users/${encodeURIComponent(u.username)}`, u.username, loadAccess),
+  )));
+  document.getElementById("token-list").replaceChildren(...tokens.tokens.map((t) => el("li", {},
+    el("span", {}, t.name, " ", el("span", { class: "type" }, `${t.username}, ${when(t.created_at)}`)),
+    remove(`/auth/tokens/${encodeURIComponent(t.id)}`, t.name, loadAccess),
+  )));
+}
+
+async function submitAccess(ev, path, body) {
+  ev.preventDefault();
+  document.getElementById("access-error").textContent = "";
+  try {
+    const result = await request("POST", path, JSON.stringify(body), "application/json");
+    ev.target.reset();
+    if (result.secret) {
+      const p = document.getElementById("token-secret");
+      p.querySelector("code").textContent = result.secret;
+      p.hidden = false;
+    }
+    loadAccess();
+  } catch (err) {
+    document.getElementById("access-error").textContent = err.message;
+  }
+}
+
+document.getElementById("user-form").addEventListener("submit", (ev) => submitAccess(ev, "/auth/users",
+  { username: ev.target.username.value, password: ev.target.password.value }));
+document.getElementById("token-form").addEventListener("submit", (ev) => submitAccess(ev, "/auth/tokens",
+  { name: ev.target.elements.name.value }));
+document.getElementById("logout").addEventListener("click", async () => {
+  await request("POST", "/auth/logout");
+  location.href = "login.html";
+});
+document.getElementById("event-filter").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  loadEvents();
+});
+for (const button of document.querySelectorAll("[data-new]")) {
+  button.addEventListener("click", () => edit(button.dataset.new));
+}
+window.addEventListener("hashchange", show);
+show();
@@ -0,0 +1,17 @@
+"use strict";
+
+document.getElementById("login-form").addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  const form = ev.target;
+  const resp = await fetch("../auth/login", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ username: form.username.value, password: form.password.value }),
+  });
+  if (!resp.ok) {
+    const data = await resp.json().catch(() => ({ message: resp.statusText }));
+    document.getElementById("login-error").textContent = data.message;
+    return;
+  }
+  location.href = "./";
+});
@@ -0,0 +1,25 @@
+// Package ui serves Informer's web UI, a static single page app built on
+// the /api/v1 admin API.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the UI. It expects to be mounted with its prefix stripped.
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // static is embedded, so this can only fail at build time
+	}
+	return http.FileServer(http.FS(sub))
+}
+
+// Public are the files needed to sign in, which must be served without
+// authentication.
+var Public = []string{"login.html", "login.js", "style.css"}